@@ -0,0 +1,365 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cheezecakee/eww-notify-go/internal/config"
+	"github.com/cheezecakee/eww-notify-go/internal/daemon"
+	"github.com/cheezecakee/eww-notify-go/internal/util/logging"
+)
+
+// subcommands are invoked as `eww-notify <name> [args]` and talk to a
+// running daemon
+var subcommands = map[string]func(args []string) error{
+	"count":                runCount,
+	"center":               runCenter,
+	"send":                 runSend,
+	"history":              runHistory,
+	"subscribe":            runSubscribe,
+	"dnd":                  runDnd,
+	"log-level":            runLogLevel,
+	"stats":                runStats,
+	"reply":                runReply,
+	"default-action":       runDefaultAction,
+	"init-config":          runInitConfig,
+	"import-config":        runImportConfig,
+	"install-dbus-service": runInstallDBusService,
+	"menu":                 runMenu,
+}
+
+// runSubcommand runs the named subcommand, reporting false if it is unknown
+func runSubcommand(name string, args []string) bool {
+	run, ok := subcommands[name]
+	if !ok {
+		return false
+	}
+
+	if err := run(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// runCount prints the number of active notifications matching the filters
+func runCount(args []string) error {
+	fs := flag.NewFlagSet("count", flag.ExitOnError)
+	urgency := fs.String("urgency", "", "Only count notifications with this urgency (low, normal, critical)")
+	app := fs.String("app", "", "Only count notifications from this app")
+	fs.Parse(args)
+
+	command := "count"
+	if *urgency != "" {
+		command += " --urgency " + *urgency
+	}
+	if *app != "" {
+		command += " --app " + *app
+	}
+
+	reply, err := daemon.QueryIPCCommand(command)
+	if err != nil {
+		return err
+	}
+	fmt.Println(strings.TrimSpace(reply))
+	return nil
+}
+
+// runCenter opens, closes or toggles the notification center window
+func runCenter(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: center toggle|open|close")
+	}
+
+	switch args[0] {
+	case "toggle", "open", "close":
+	default:
+		return fmt.Errorf("unknown center operation '%s'", args[0])
+	}
+
+	return daemon.SendIPCCommand("center " + args[0])
+}
+
+// runHistory lists, clears or re-displays closed notifications
+func runHistory(args []string) error {
+	if len(args) > 0 && args[0] == "query" {
+		return runHistoryQuery(args[1:])
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: history list|clear|pop|query")
+	}
+
+	switch args[0] {
+	case "list":
+		reply, err := daemon.QueryIPCCommand("history list")
+		if err != nil {
+			return err
+		}
+		fmt.Print(reply)
+		return nil
+	case "clear", "pop":
+		return daemon.SendIPCCommand("history " + args[0])
+	default:
+		return fmt.Errorf("unknown history operation '%s'", args[0])
+	}
+}
+
+// runHistoryQuery searches the persistent history store
+func runHistoryQuery(args []string) error {
+	fs := flag.NewFlagSet("history query", flag.ExitOnError)
+	app := fs.String("app", "", "Only notifications from this app")
+	since := fs.String("since", "", "Closed after this time (RFC 3339, date, unix seconds or a duration ago like 2h)")
+	until := fs.String("until", "", "Closed before this time, same formats as -since")
+	text := fs.String("text", "", "Words that must all appear in the summary or body")
+	limit := fs.Uint("limit", 0, "Return at most this many entries, newest first (0 = all)")
+	fs.Parse(args)
+
+	command := "history query"
+	if *app != "" {
+		command += " --app " + *app
+	}
+	if *since != "" {
+		command += " --since " + *since
+	}
+	if *until != "" {
+		command += " --until " + *until
+	}
+	if *text != "" {
+		command += " --text " + *text
+	}
+	if *limit > 0 {
+		command += fmt.Sprintf(" --limit %d", *limit)
+	}
+
+	reply, err := daemon.QueryIPCCommand(command)
+	if err != nil {
+		return err
+	}
+	fmt.Print(reply)
+	return nil
+}
+
+// runSubscribe prints daemon events as JSON lines until interrupted
+func runSubscribe(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: subscribe")
+	}
+	return daemon.StreamIPCCommand("subscribe", os.Stdout)
+}
+
+// runDnd switches Do-Not-Disturb or prints its state
+func runDnd(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: dnd on|off|toggle|status")
+	}
+
+	switch args[0] {
+	case "on", "off", "toggle":
+		return daemon.SendIPCCommand("dnd " + args[0])
+	case "status":
+		reply, err := daemon.QueryIPCCommand("dnd status")
+		if err != nil {
+			return err
+		}
+		fmt.Print(reply)
+		return nil
+	default:
+		return fmt.Errorf("unknown dnd operation '%s'", args[0])
+	}
+}
+
+// runStats prints the daemon's counters as JSON, or in the Prometheus text
+// format
+func runStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	prometheus := fs.Bool("prometheus", false, "Print the Prometheus text format instead of JSON")
+	fs.Parse(args)
+
+	command := "stats"
+	if *prometheus {
+		command += " prometheus"
+	}
+	reply, err := daemon.QueryIPCCommand(command)
+	if err != nil {
+		return err
+	}
+	fmt.Print(reply)
+	return nil
+}
+
+// runLogLevel prints the daemon's log level, or changes it until the
+// daemon exits
+func runLogLevel(args []string) error {
+	switch len(args) {
+	case 0:
+		reply, err := daemon.QueryIPCCommand("log-level")
+		if err != nil {
+			return err
+		}
+		fmt.Print(reply)
+		return nil
+	case 1:
+		if _, err := logging.ParseLevel(args[0]); err != nil {
+			return err
+		}
+		return daemon.SendIPCCommand("log-level " + args[0])
+	default:
+		return fmt.Errorf("usage: log-level [error|warn|info|debug]")
+	}
+}
+
+// runReply answers a notification's inline reply action with text
+func runReply(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: reply <id|latest> <text>")
+	}
+	if _, err := strconv.ParseUint(args[0], 10, 32); err != nil && args[0] != "latest" {
+		return fmt.Errorf("invalid notification ID '%s'", args[0])
+	}
+
+	return daemon.SendIPCCommand("reply " + strings.Join(args, " "))
+}
+
+// runDefaultAction invokes a notification's "default" action, or its first
+// one, for widgets where clicking anywhere should do the sensible thing
+func runDefaultAction(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: default-action <id|latest>")
+	}
+	if _, err := strconv.ParseUint(args[0], 10, 32); err != nil && args[0] != "latest" {
+		return fmt.Errorf("invalid notification ID '%s'", args[0])
+	}
+
+	return daemon.SendIPCCommand("default-action " + args[0])
+}
+
+// runInstallDBusService writes the D-Bus service file that lets the bus
+// start the daemon when the first notification arrives
+func runInstallDBusService(args []string) error {
+	fs := flag.NewFlagSet("install-dbus-service", flag.ExitOnError)
+	force := fs.Bool("force", false, "Overwrite an existing service file")
+	portal := fs.Bool("portal", false, "Also register as the xdg-desktop-portal notification backend")
+	fs.Parse(args)
+
+	path, err := daemon.InstallDBusService(*force)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote D-Bus service file to %s\n", path)
+
+	if *portal {
+		path, err := daemon.InstallPortal(*force)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote portal file to %s\n", path)
+		fmt.Printf("Enable it with portal-backend = true and add this line to the [preferred] section of portals.conf:\n  %s\n", daemon.PortalConfLine)
+	}
+	return nil
+}
+
+// runInitConfig writes a commented default config file
+func runInitConfig(args []string) error {
+	fs := flag.NewFlagSet("init-config", flag.ExitOnError)
+	force := fs.Bool("force", false, "Overwrite an existing config file")
+	fs.Parse(args)
+
+	path, err := config.GetConfigPath()
+	if err != nil {
+		return err
+	}
+
+	if err := config.WriteDefaultConfig(path, *force); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote default config to %s\n", path)
+	return nil
+}
+
+// runImportConfig translates a dunstrc or mako config into an end config,
+// printing it unless -write is given
+func runImportConfig(args []string) error {
+	fs := flag.NewFlagSet("import-config", flag.ExitOnError)
+	from := fs.String("from", "", "Config format to read: dunst or mako (guessed from the path by default)")
+	write := fs.Bool("write", false, "Write the result to the end config path instead of printing it")
+	force := fs.Bool("force", false, "With -write, overwrite an existing config file")
+	fs.Parse(args)
+
+	path := fs.Arg(0)
+	if path == "" {
+		found, err := findImportSource()
+		if err != nil {
+			return err
+		}
+		path = found
+	}
+
+	var source config.ImportSource
+	if *from != "" {
+		if err := source.UnmarshalText([]byte(*from)); err != nil {
+			return err
+		}
+	} else {
+		switch {
+		case filepath.Base(path) == "dunstrc":
+			source = config.ImportDunst
+		case filepath.Base(filepath.Dir(path)) == "mako":
+			source = config.ImportMako
+		default:
+			return fmt.Errorf("cannot tell the format of %s, pass -from dunst or -from mako", path)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	imported, err := config.Import(source, file)
+	if err != nil {
+		return fmt.Errorf("failed to import %s: %w", path, err)
+	}
+
+	if !*write {
+		return imported.WriteTOML(os.Stdout, path)
+	}
+
+	target, err := config.GetConfigPath()
+	if err != nil {
+		return err
+	}
+	if err := config.WriteImportedConfig(target, imported, path, *force); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote config imported from %s to %s\n", path, target)
+	if len(imported.Skipped) > 0 {
+		fmt.Printf("%d settings could not be translated, see the comments at the top\n", len(imported.Skipped))
+	}
+	return nil
+}
+
+// findImportSource looks for a dunst or mako config in the usual places
+func findImportSource() (string, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	candidates := []string{
+		filepath.Join(configDir, "dunst", "dunstrc"),
+		filepath.Join(configDir, "mako", "config"),
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no dunstrc or mako config found in %s, pass its path", configDir)
+}
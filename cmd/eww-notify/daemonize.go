@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/cheezecakee/eww-notify-go/internal/daemon"
+	"github.com/cheezecakee/eww-notify-go/internal/util/constants"
+)
+
+// daemonizeTimeout bounds how long -daemon waits for the background daemon
+// to come up before leaving it to itself
+const daemonizeTimeout = 10 * time.Second
+
+// isDaemonized reports whether this process is the background daemon
+// started by -daemon
+func isDaemonized() bool {
+	return os.Getenv(constants.DaemonizedEnvVar) != ""
+}
+
+// daemonize starts this binary again in the background, detached from the
+// terminal in its own session with its output in logPath, and waits until
+// it has written its PID file. Go cannot fork, so the child is a fresh exec
+// that sees DaemonizedEnvVar and runs the daemon in the foreground.
+func daemonize(logPath string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find executable: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(logPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	logOutput, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer logOutput.Close()
+
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		return err
+	}
+	defer devNull.Close()
+
+	// The log package writes to logPath too, so SIGUSR1 can reopen it
+	args := append([]string{"-log-file", logPath}, os.Args[1:]...)
+	cmd := exec.Command(executable, args...)
+	cmd.Env = append(os.Environ(), constants.DaemonizedEnvVar+"=1")
+	cmd.Stdin = devNull
+	cmd.Stdout = logOutput
+	cmd.Stderr = logOutput
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start background daemon: %w", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	deadline := time.After(daemonizeTimeout)
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-exited:
+			return fmt.Errorf("background daemon exited (%v), see %s", err, logPath)
+		case <-deadline:
+			fmt.Printf("Background daemon (pid %d) is still starting, see %s\n", cmd.Process.Pid, logPath)
+			return cmd.Process.Release()
+		case <-ticker.C:
+			if daemon.ReadPidFile() == cmd.Process.Pid {
+				fmt.Printf("Daemon running in the background (pid %d), logging to %s\n", cmd.Process.Pid, logPath)
+				return cmd.Process.Release()
+			}
+		}
+	}
+}
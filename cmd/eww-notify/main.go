@@ -1,16 +1,24 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"os/signal"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/cheezecakee/eww-notify-go/internal/config"
 	"github.com/cheezecakee/eww-notify-go/internal/daemon"
+	"github.com/cheezecakee/eww-notify-go/internal/util/constants"
+	"github.com/cheezecakee/eww-notify-go/internal/util/logfile"
+	"github.com/cheezecakee/eww-notify-go/internal/util/logging"
+	"github.com/cheezecakee/eww-notify-go/internal/util/systemd"
 )
 
 // Command line options
@@ -23,24 +31,116 @@ func main() {
 	// Define flags
 	var (
 		stopFlag   = flag.Bool("stop", false, "Stop the notification daemon")
-		closeFlag  = flag.String("close", "", "Close notification by ID")
-		actionFlag = flag.String("action", "", "Invoke action (format: 'id actionkey')")
+		statusFlag = flag.Bool("status", false, "Show daemon status")
+		reloadFlag = flag.Bool("reload", false, "Reload the daemon configuration")
+		pauseFlag  = flag.Bool("pause", false, "Freeze all notification timeouts")
+		resumeFlag = flag.Bool("resume", false, "Resume notification timeouts")
+		listFlag   = flag.Bool("list", false, "Print active notifications as JSON")
+		closeFlag  = flag.String("close", "", "Close notification by ID (or 'latest')")
+		closeFrom  = flag.String("close-from", "", "Close all notifications of an app (name or D-Bus sender)")
+		actionFlag = flag.String("action", "", "Invoke action (format: 'id actionkey', id may be 'latest')")
+		cycleFlag  = flag.String("cycle", "", "Cycle through the stacked notifications of an app")
+		expandFlag = flag.String("expand", "", "Show every notification of an app's group (grouped display mode)")
+		collapse   = flag.String("collapse", "", "Collapse an app's group back to its newest notification")
+		selectFlag = flag.String("select", "", "Keyboard navigation (next, prev, activate, dismiss)")
+		extendFlag = flag.String("extend", "", "Extend a notification's timeout (format: 'id duration')")
+		holdFlag   = flag.String("pause-timeout", "", "Stop a notification from expiring, e.g. on hover (ID or 'latest')")
+		unholdFlag = flag.String("resume-timeout", "", "Let a notification paused with -pause-timeout expire again")
+		pinFlag    = flag.String("pin", "", "Keep a notification on screen until closed (ID or 'latest')")
+		unpinFlag  = flag.String("unpin", "", "Unpin a notification, restarting its timeout")
+		muteFlag   = flag.String("mute", "", "Suppress popups from an app until the daemon restarts")
+		unmuteFlag = flag.String("unmute", "", "Stop suppressing popups from an app")
+		mutedFlag  = flag.Bool("muted", false, "List muted apps")
+		setFlag    = flag.String("set", "", "Change a setting for this session (format: 'key value')")
+		getFlag    = flag.Bool("get", false, "Show effective runtime settings (optionally pass keys as arguments), or a notification as JSON when given its ID")
 		version    = flag.Bool("version", false, "Show version information")
+		instance   = flag.String("instance", "", "Name of the daemon instance to run or control")
+		socketFlag = flag.String("socket", "", "IPC socket path (overrides $"+constants.SocketEnvVar+", ipc-socket and -instance)")
+		configFlag = flag.String("config", "", "Config file path (overrides $"+constants.ConfigEnvVar+")")
+		logFile    = flag.String("log-file", "", "Write daemon logs to this file (reopened on SIGUSR1)")
+		logLevel   = flag.String("log-level", "", "Daemon log level: error, warn, info or debug (overrides log-level in config.toml)")
+		daemonFlag = flag.Bool("daemon", false, "Run the daemon in the background with a PID file, logging to -log-file or $XDG_STATE_HOME/end/end.log")
+		replace    = flag.Bool("replace", false, "Stop the running daemon and take over its bus name")
+		noIPC      = flag.Bool("no-ipc", false, "Run without the IPC socket (control through D-Bus only)")
 	)
 
+	flag.BoolVar(daemonFlag, "d", false, "Shorthand for -daemon")
+
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "\nOptions:\n")
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nExamples:\n")
 		fmt.Fprintf(os.Stderr, "  %s                    # Start daemon\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -d                 # Start daemon in the background\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -stop              # Stop daemon\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -replace           # Start daemon, replacing the running one\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -reload            # Re-read config.toml\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -close 123         # Close notification with ID 123\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -close latest      # Close the most recent notification\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -action latest     # Invoke the default action of the most recent notification\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -list              # Print active notifications as JSON\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -action \"123 ok\"   # Invoke 'ok' action on notification 123\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -cycle firefox     # Show the next stacked firefox notification\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -expand firefox    # Expand the grouped firefox notifications\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -select next       # Select the next notification\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -instance left     # Start a second daemon named 'left'\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -config ~/.config/end/work.toml # Start with another config file\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -set \"timeout.normal 3\" # Shorten normal timeouts until restart\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nCommands:\n")
+		fmt.Fprintf(os.Stderr, "  %s count [-urgency X] [-app Y]  # Print the number of active notifications\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s center toggle|open|close     # Control the notification center window\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s send [options] summary [body] # Post a notification like notify-send\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s history list|clear|pop       # Inspect or restore closed notifications\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s history query [-app a] [-since t] [-until t] [-text s] [-limit n] # Search the history store\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s subscribe                    # Stream notification events as JSON lines\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s dnd on|off|toggle|status     # Control Do-Not-Disturb mode\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s log-level [error|warn|info|debug] # Show or change the daemon's log level\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s stats [-prometheus]          # Print counters per app since the daemon started\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s reply <id> <text>            # Answer a notification's inline reply field\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s default-action <id>          # Invoke the default action, or the first one\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s menu [-history] | rofi -dmenu | %s menu -pick [-dismiss] # Pick a notification from a launcher\n", os.Args[0], os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s init-config [-force]         # Write a commented default config.toml\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s install-dbus-service [-force] [-portal] # Start the daemon on the first notification\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s import-config [-from dunst|mako] [-write [-force]] [path] # Translate a dunstrc or mako config\n", os.Args[0])
 	}
 
 	flag.Parse()
 
+	logging.Setup(os.Stderr)
+	if *logLevel != "" {
+		level, err := logging.ParseLevel(*logLevel)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		logging.SetLevel(level)
+	}
+
+	switch {
+	case *configFlag != "":
+		config.SetConfigPath(*configFlag)
+	case os.Getenv(constants.ConfigEnvVar) != "":
+		config.SetConfigPath(os.Getenv(constants.ConfigEnvVar))
+	}
+
+	// Every instance gets its own IPC socket, unless one is given explicitly
+	configSocket := configuredSocketPath()
+	switch {
+	case *socketFlag != "":
+		daemon.SetSocketPath(*socketFlag)
+	case os.Getenv(constants.SocketEnvVar) != "":
+		daemon.SetSocketPath(os.Getenv(constants.SocketEnvVar))
+	case configSocket != "":
+		daemon.SetSocketPath(configSocket)
+	default:
+		daemon.SetSocketPath(constants.GetSocketPath(*instance))
+	}
+
+	daemon.SetStatePath(constants.GetStatePath(*instance))
+	daemon.SetHistoryPath(constants.GetHistoryPath(*instance))
+	daemon.SetImageDir(constants.GetImageDir(*instance))
+
 	// Handle version flag
 	if *version {
 		fmt.Printf("eww-notification-daemon v1.2.0\n")
@@ -57,9 +157,135 @@ func main() {
 		return
 	}
 
+	if *reloadFlag {
+		if err := daemon.SendIPCCommand("reload"); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Reload command sent to daemon")
+		return
+	}
+
+	if *pauseFlag || *resumeFlag {
+		command := "pause"
+		if *resumeFlag {
+			command = "resume"
+		}
+		if err := daemon.SendIPCCommand(command); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *statusFlag {
+		reply, err := daemon.QueryIPCCommand("status")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(reply)
+		return
+	}
+
+	if *extendFlag != "" {
+		parts := strings.Fields(*extendFlag)
+		if len(parts) != 2 {
+			fmt.Fprintf(os.Stderr, "Error: Extend flag requires format 'id duration'\n")
+			os.Exit(1)
+		}
+		if _, err := strconv.ParseUint(parts[0], 10, 32); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid notification ID '%s'\n", parts[0])
+			os.Exit(1)
+		}
+		if err := daemon.SendIPCCommand("extend " + *extendFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *holdFlag != "" || *unholdFlag != "" {
+		command := "pause-timeout " + *holdFlag
+		if *unholdFlag != "" {
+			command = "resume-timeout " + *unholdFlag
+		}
+		if err := daemon.SendIPCCommand(command); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *pinFlag != "" || *unpinFlag != "" {
+		command := "pin " + *pinFlag
+		if *unpinFlag != "" {
+			command = "unpin " + *unpinFlag
+		}
+		if err := daemon.SendIPCCommand(command); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *muteFlag != "" || *unmuteFlag != "" {
+		command := "mute " + *muteFlag
+		if *unmuteFlag != "" {
+			command = "unmute " + *unmuteFlag
+		}
+		if err := daemon.SendIPCCommand(command); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *mutedFlag {
+		reply, err := daemon.QueryIPCCommand("muted")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(reply)
+		return
+	}
+
+	if *setFlag != "" {
+		if len(strings.Fields(*setFlag)) != 2 {
+			fmt.Fprintf(os.Stderr, "Error: Set flag requires format 'key value'\n")
+			os.Exit(1)
+		}
+		if err := daemon.SendIPCCommand("set " + *setFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *getFlag {
+		reply, err := daemon.QueryIPCCommand(strings.TrimSpace("get " + strings.Join(flag.Args(), " ")))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(reply)
+		return
+	}
+
+	if *listFlag {
+		reply, err := daemon.QueryIPCCommand("list")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(reply)
+		return
+	}
+
 	if *closeFlag != "" {
 		// Validate ID is numeric
-		if _, err := strconv.ParseUint(*closeFlag, 10, 32); err != nil {
+		if _, err := strconv.ParseUint(*closeFlag, 10, 32); err != nil && *closeFlag != "latest" {
 			fmt.Fprintf(os.Stderr, "Error: Invalid notification ID '%s'\n", *closeFlag)
 			os.Exit(1)
 		}
@@ -72,15 +298,23 @@ func main() {
 		return
 	}
 
+	if *closeFrom != "" {
+		if err := daemon.SendIPCCommand("close-from " + *closeFrom); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if *actionFlag != "" {
 		parts := strings.Fields(*actionFlag)
-		if len(parts) != 2 {
+		if len(parts) != 2 && *actionFlag != "latest" {
 			fmt.Fprintf(os.Stderr, "Error: Action flag requires format 'id actionkey'\n")
 			os.Exit(1)
 		}
 
 		// Validate ID is numeric
-		if _, err := strconv.ParseUint(parts[0], 10, 32); err != nil {
+		if _, err := strconv.ParseUint(parts[0], 10, 32); err != nil && parts[0] != "latest" {
 			fmt.Fprintf(os.Stderr, "Error: Invalid notification ID '%s'\n", parts[0])
 			os.Exit(1)
 		}
@@ -93,42 +327,254 @@ func main() {
 		return
 	}
 
+	if *cycleFlag != "" {
+		if err := daemon.SendIPCCommand("cycle " + *cycleFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Cycle command sent for %s\n", *cycleFlag)
+		return
+	}
+
+	if *expandFlag != "" {
+		if err := daemon.SendIPCCommand("expand " + *expandFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Expand command sent for %s\n", *expandFlag)
+		return
+	}
+
+	if *collapse != "" {
+		if err := daemon.SendIPCCommand("collapse " + *collapse); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Collapse command sent for %s\n", *collapse)
+		return
+	}
+
+	if *selectFlag != "" {
+		var command string
+		switch *selectFlag {
+		case "next", "prev":
+			command = "select-" + *selectFlag
+		case "activate", "dismiss":
+			command = *selectFlag + "-selected"
+		default:
+			fmt.Fprintf(os.Stderr, "Error: Unknown select operation '%s'\n", *selectFlag)
+			os.Exit(1)
+		}
+
+		if err := daemon.SendIPCCommand(command); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.NArg() > 0 {
+		if !runSubcommand(flag.Arg(0), flag.Args()[1:]) {
+			fmt.Fprintf(os.Stderr, "Error: Unknown command '%s'\n", flag.Arg(0))
+			flag.Usage()
+			os.Exit(1)
+		}
+		return
+	}
+
 	// No flags provided - start daemon
-	if err := startDaemon(); err != nil {
+	if *daemonFlag && !isDaemonized() {
+		logPath := *logFile
+		if logPath == "" {
+			if cfg, err := config.LoadConfig(); err == nil {
+				logPath = configuredLogPath(*cfg, *instance)
+			}
+		}
+		if logPath == "" {
+			logPath = constants.GetLogPath(*instance)
+		}
+		if err := daemonize(logPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to start daemon: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	opts := startOptions{
+		logPath:  *logFile,
+		instance: *instance,
+		noIPC:    *noIPC,
+		replace:  *replace,
+		pidFile:  isDaemonized(),
+	}
+	if err := startDaemon(opts); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to start daemon: %v\n", err)
 		os.Exit(1)
 	}
 }
 
+// handleSignal reloads the config on SIGHUP, reopens the log file
+// on SIGUSR1 (for logrotate) and dumps goroutines and state on SIGUSR2
+func handleSignal(sig os.Signal, d *daemon.Daemon, logOutput *logfile.File) {
+	switch sig {
+	case syscall.SIGHUP:
+		systemd.Notify("RELOADING=1")
+		if err := d.Reload(); err != nil {
+			slog.Error("Failed to reload", "err", err)
+		}
+		systemd.Notify("READY=1")
+	case syscall.SIGUSR1:
+		if logOutput == nil {
+			return
+		}
+		if err := logOutput.Reopen(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to reopen log file: %v\n", err)
+			return
+		}
+		slog.Info("Reopened log file")
+	case syscall.SIGUSR2:
+		// Asked for explicitly, so written whatever the log level
+		var output io.Writer = os.Stderr
+		if logOutput != nil {
+			output = logOutput
+		}
+		fmt.Fprintf(output, "Diagnostic dump requested\n%s", d.DumpDiagnostics())
+	}
+}
+
+// configuredSocketPath returns the ipc-socket set in config.toml or its
+// environment override, if any
+func configuredSocketPath() string {
+	if path := os.Getenv(config.EnvName("ipc-socket")); path != "" {
+		return path
+	}
+
+	path, err := config.GetConfigPath()
+	if err != nil {
+		return ""
+	}
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil || cfg.IPCSocket == nil {
+		return ""
+	}
+	return *cfg.IPCSocket
+}
+
+// configuredLogPath returns the log file set up in [config.log-file], ""
+// when the daemon logs to stderr
+func configuredLogPath(cfg config.Config, instance string) string {
+	if !cfg.LogFile.Enabled {
+		return ""
+	}
+	if cfg.LogFile.Path != "" {
+		return cfg.LogFile.Path
+	}
+	return constants.GetLogPath(instance)
+}
+
+// startOptions are the command line settings affecting the daemon itself
+type startOptions struct {
+	logPath  string
+	instance string
+	noIPC    bool
+	replace  bool
+	pidFile  bool
+}
+
+// acquireInstanceLock makes sure no other daemon uses our socket, after
+// -replace the old daemon gets until replaceTimeout to let go of it
+func acquireInstanceLock(replace bool) (*daemon.InstanceLock, error) {
+	deadline := time.Now().Add(replaceTimeout)
+	for {
+		lock, err := daemon.AcquireInstanceLock()
+		if err == nil {
+			return lock, nil
+		}
+		if !errors.Is(err, daemon.ErrAlreadyRunning) || !replace || time.Now().After(deadline) {
+			if errors.Is(err, daemon.ErrAlreadyRunning) {
+				return nil, fmt.Errorf("%w, stop it first or start with -replace", err)
+			}
+			return nil, err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// replaceTimeout bounds how long -replace waits for the old daemon to exit
+const replaceTimeout = 5 * time.Second
+
+// replaceRunningDaemon asks the daemon on our socket to exit and waits until
+// the notification bus name is free
+func replaceRunningDaemon() {
+	if err := daemon.SendIPCCommand("kill"); err != nil {
+		slog.Info("No daemon answered, taking over the bus name directly", "socket", daemon.GetSocketPath())
+	}
+
+	if err := daemon.WaitForNameRelease(replaceTimeout); err != nil {
+		slog.Warn("Old daemon did not release the bus name", "err", err)
+	}
+}
+
 // startDaemon starts the notification daemon
-func startDaemon() error {
+func startDaemon(opts startOptions) error {
 	// Load configuration
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		// Fall back to default config with warning
-		fmt.Printf("Warning: Could not load config (%v), using defaults\n", err)
-		defaultCfg := config.DefaultConfig
-		cfg = &defaultCfg
+		return err
 	}
 
+	logPath := opts.logPath
+	if logPath == "" {
+		logPath = configuredLogPath(*cfg, opts.instance)
+	}
+
+	var logOutput *logfile.File
+	if logPath != "" {
+		logOutput, err = logfile.Open(logPath)
+		if err != nil {
+			return err
+		}
+		defer logOutput.Close()
+		logOutput.SetRotation(int64(cfg.LogFile.MaxSize)<<20, int(cfg.LogFile.MaxBackups))
+		logging.Setup(logOutput)
+	}
+
+	if opts.replace {
+		replaceRunningDaemon()
+	}
+
+	lock, err := acquireInstanceLock(opts.replace)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
 	// Create daemon
 	d, err := daemon.NewDaemon(*cfg)
 	if err != nil {
 		return fmt.Errorf("failed to create daemon: %w", err)
 	}
 
-	// Create IPC server
-	ipcServer := daemon.NewIPCServer(d)
+	if opts.noIPC || cfg.DisableIPC {
+		slog.Info("IPC server disabled, control is only available through D-Bus")
+	} else {
+		// Create IPC server
+		ipcServer := daemon.NewIPCServer(d)
 
-	// Start IPC server
-	if err := ipcServer.Start(); err != nil {
-		return fmt.Errorf("failed to start IPC server: %w", err)
-	}
-	defer func() {
-		if err := ipcServer.Stop(); err != nil {
-			fmt.Printf("Warning: Failed to stop IPC server: %v\n", err)
+		// Start IPC server
+		if err := ipcServer.Start(); err != nil {
+			return fmt.Errorf("failed to start IPC server: %w", err)
 		}
-	}()
+		defer func() {
+			if err := ipcServer.Stop(); err != nil {
+				slog.Warn("Failed to stop IPC server", "err", err)
+			}
+		}()
+	}
 
 	// Start daemon
 	if err := d.Start(); err != nil {
@@ -136,18 +582,58 @@ func startDaemon() error {
 	}
 	defer func() {
 		if err := d.Stop(); err != nil {
-			fmt.Printf("Warning: Failed to stop daemon: %v\n", err)
+			slog.Warn("Failed to stop daemon", "err", err)
 		}
 	}()
 
-	// Set up signal handling for graceful shutdown
+	// -daemon waits for the PID file to know the daemon is serving
+	if opts.pidFile {
+		if err := daemon.WritePidFile(); err != nil {
+			slog.Error("Failed to write PID file", "err", err)
+		}
+		defer daemon.RemovePidFile()
+	}
+
+	// Tell systemd (Type=notify) the daemon is serving
+	if err := systemd.Notify("READY=1"); err != nil {
+		slog.Warn("Failed to notify systemd", "err", err)
+	}
+	if interval := systemd.WatchdogInterval(); interval > 0 {
+		go pingWatchdog(interval)
+	}
+
+	// Set up signal handling for graceful shutdown and diagnostics
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2)
 
 	// Wait for shutdown signal
-	fmt.Println("Daemon is running. Press Ctrl+C to stop.")
-	<-sigChan
+	slog.Info("Daemon is running, press Ctrl+C to stop")
+wait:
+	for {
+		select {
+		case sig := <-sigChan:
+			if sig == syscall.SIGINT || sig == syscall.SIGTERM {
+				break wait
+			}
+			handleSignal(sig, d, logOutput)
+		case <-d.Done():
+			break wait
+		}
+	}
 
-	fmt.Println("\nShutting down daemon...")
+	slog.Info("Shutting down daemon")
+	systemd.Notify("STOPPING=1")
 	return nil
 }
+
+// pingWatchdog keeps the systemd watchdog from restarting the daemon
+func pingWatchdog(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := systemd.Notify("WATCHDOG=1"); err != nil {
+			slog.Warn("Failed to ping the systemd watchdog", "err", err)
+		}
+	}
+}
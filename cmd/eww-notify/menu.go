@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cheezecakee/eww-notify-go/internal/daemon"
+)
+
+// menuEntry holds the fields of the list and history JSON shown in the menu
+type menuEntry struct {
+	Id      uint32 `json:"id"`
+	AppName string `json:"app_name"`
+	Summary string `json:"summary"`
+	Body    string `json:"body"`
+}
+
+// runMenu prints notifications one per line for rofi/dmenu, or with -pick
+// reads the chosen line back from stdin and acts on it:
+//
+//	eww-notify menu | rofi -dmenu | eww-notify menu -pick
+func runMenu(args []string) error {
+	fs := flag.NewFlagSet("menu", flag.ExitOnError)
+	history := fs.Bool("history", false, "Also list closed notifications")
+	pick := fs.Bool("pick", false, "Read a selected line from stdin and act on it")
+	dismiss := fs.Bool("dismiss", false, "With -pick, dismiss instead of invoking the default action")
+	fs.Parse(args)
+
+	if *pick {
+		return pickMenuEntry(*dismiss)
+	}
+
+	active, err := queryMenuEntries("list")
+	if err != nil {
+		return err
+	}
+	for _, entry := range active {
+		fmt.Println(formatMenuEntry(strconv.FormatUint(uint64(entry.Id), 10), entry))
+	}
+
+	if *history {
+		closed, err := queryMenuEntries("history list")
+		if err != nil {
+			return err
+		}
+		for _, entry := range closed {
+			fmt.Println(formatMenuEntry("h"+strconv.FormatUint(uint64(entry.Id), 10), entry))
+		}
+	}
+
+	return nil
+}
+
+// queryMenuEntries decodes the JSON reply of a list command
+func queryMenuEntries(command string) ([]menuEntry, error) {
+	reply, err := daemon.QueryIPCCommand(command)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []menuEntry
+	if err := json.Unmarshal([]byte(reply), &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse %s reply: %w", command, err)
+	}
+	return entries, nil
+}
+
+// formatMenuEntry renders a notification as a single line starting with its
+// key, closed notifications use an "h" prefix
+func formatMenuEntry(key string, entry menuEntry) string {
+	text := entry.AppName + ": " + entry.Summary
+	if entry.Body != "" {
+		text += " - " + entry.Body
+	}
+	// Multi-line bodies would split one notification across menu rows
+	return key + "\t" + strings.Join(strings.Fields(text), " ")
+}
+
+// pickMenuEntry reads a line produced by runMenu from stdin and dismisses the
+// notification or invokes its default action
+func pickMenuEntry(dismiss bool) error {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		// Nothing picked, e.g. the menu was cancelled
+		return nil
+	}
+
+	key, _, _ := strings.Cut(strings.TrimSpace(line), "\t")
+	if key == "" {
+		return nil
+	}
+	if strings.HasPrefix(key, "h") {
+		return fmt.Errorf("notification %s is already closed", key[1:])
+	}
+
+	id, err := strconv.ParseUint(key, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid menu selection '%s'", key)
+	}
+
+	if dismiss {
+		return daemon.SendIPCCommand(fmt.Sprintf("close %d", id))
+	}
+	return daemon.SendIPCCommand(fmt.Sprintf("action %d", id))
+}
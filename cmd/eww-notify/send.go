@@ -0,0 +1,230 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/cheezecakee/eww-notify-go/internal/daemon"
+	"github.com/cheezecakee/eww-notify-go/internal/state"
+)
+
+// stringList collects a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// runSend posts a notification over D-Bus the way notify-send does and
+// prints the ID the daemon assigned
+func runSend(args []string) error {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: send [options] <summary> [body]\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+
+	var (
+		urgency   = fs.String("urgency", "normal", "Urgency level (low, normal, critical)")
+		icon      = fs.String("icon", "", "Icon name or path")
+		appName   = fs.String("app-name", "eww-notify", "Application name")
+		expire    = fs.Int("expire-time", -1, "Timeout in milliseconds (-1 uses the daemon default, 0 never expires)")
+		replaceId = fs.Uint("replace-id", 0, "ID of the notification to replace")
+		category  = fs.String("category", "", "Notification category")
+		wait      = fs.Bool("wait", false, "Wait until the notification is closed or an action is invoked; exits 0 on action, 2-5 on close (expired, dismissed, closed, other)")
+		hints     stringList
+		actions   stringList
+	)
+	fs.Var(&hints, "hint", "Extra hint in TYPE:NAME:VALUE form, TYPE is int, double, string, byte or boolean (repeatable)")
+	fs.Var(&actions, "action", "Action in KEY=LABEL form (repeatable)")
+
+	// Short forms matching notify-send
+	fs.StringVar(urgency, "u", "normal", "Shorthand for -urgency")
+	fs.StringVar(icon, "i", "", "Shorthand for -icon")
+	fs.StringVar(appName, "a", "eww-notify", "Shorthand for -app-name")
+	fs.IntVar(expire, "t", -1, "Shorthand for -expire-time")
+	fs.UintVar(replaceId, "r", 0, "Shorthand for -replace-id")
+	fs.StringVar(category, "c", "", "Shorthand for -category")
+	fs.Var(&hints, "h", "Shorthand for -hint")
+	fs.Var(&actions, "A", "Shorthand for -action")
+	fs.BoolVar(wait, "w", false, "Shorthand for -wait")
+
+	fs.Parse(args)
+
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return fmt.Errorf("send requires a summary and an optional body")
+	}
+	summary := fs.Arg(0)
+	body := fs.Arg(1)
+
+	hintMap := make(map[string]dbus.Variant)
+
+	level, err := parseUrgency(*urgency)
+	if err != nil {
+		return err
+	}
+	hintMap["urgency"] = dbus.MakeVariant(level)
+
+	if *category != "" {
+		hintMap["category"] = dbus.MakeVariant(*category)
+	}
+
+	for _, hint := range hints {
+		name, value, err := parseHint(hint)
+		if err != nil {
+			return err
+		}
+		hintMap[name] = value
+	}
+
+	var actionList []string
+	for _, action := range actions {
+		key, label, ok := strings.Cut(action, "=")
+		if !ok {
+			return fmt.Errorf("invalid action '%s', expected KEY=LABEL", action)
+		}
+		actionList = append(actionList, key, label)
+	}
+
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to session bus: %w", err)
+	}
+	defer conn.Close()
+
+	// Subscribe before sending so no signal can slip through
+	var signals chan *dbus.Signal
+	if *wait {
+		if err := conn.AddMatchSignal(
+			dbus.WithMatchObjectPath(daemon.NotificationObjectPath),
+			dbus.WithMatchInterface(daemon.NotificationInterface),
+		); err != nil {
+			return fmt.Errorf("failed to subscribe to notification signals: %w", err)
+		}
+		signals = make(chan *dbus.Signal, 16)
+		conn.Signal(signals)
+	}
+
+	obj := conn.Object(daemon.NotificationServiceName, daemon.NotificationObjectPath)
+	call := obj.Call(daemon.NotificationInterface+".Notify", 0,
+		*appName, uint32(*replaceId), *icon, summary, body, actionList, hintMap, int32(*expire))
+	if call.Err != nil {
+		return fmt.Errorf("failed to send notification: %w", call.Err)
+	}
+
+	var id uint32
+	if err := call.Store(&id); err != nil {
+		return fmt.Errorf("failed to read notification ID: %w", err)
+	}
+
+	fmt.Println(id)
+
+	if *wait {
+		return waitForResult(signals, id)
+	}
+	return nil
+}
+
+// waitForResult blocks until the notification is acted upon or closed and
+// exits with a status describing what happened
+func waitForResult(signals <-chan *dbus.Signal, id uint32) error {
+	for signal := range signals {
+		if len(signal.Body) < 2 {
+			continue
+		}
+		if signalId, ok := signal.Body[0].(uint32); !ok || signalId != id {
+			continue
+		}
+
+		switch signal.Name {
+		case daemon.NotificationInterface + ".ActionInvoked":
+			actionKey, _ := signal.Body[1].(string)
+			fmt.Println(actionKey)
+			os.Exit(0)
+		case daemon.NotificationInterface + ".NotificationClosed":
+			code, _ := signal.Body[1].(uint32)
+			reason := state.CloseReasonFromCode(code)
+			fmt.Printf("closed %s\n", closeReasonName(reason))
+			os.Exit(1 + int(reason.Code()))
+		}
+	}
+	return fmt.Errorf("connection to the session bus was lost")
+}
+
+// closeReasonName names a NotificationClosed reason for the wait output
+func closeReasonName(reason state.NotificationCloseReason) string {
+	switch reason {
+	case state.Expired:
+		return "expired"
+	case state.Dismiss:
+		return "dismissed"
+	case state.CloseNotification:
+		return "closed"
+	default:
+		return "undefined"
+	}
+}
+
+func parseUrgency(urgency string) (uint8, error) {
+	switch urgency {
+	case "low":
+		return 0, nil
+	case "normal":
+		return 1, nil
+	case "critical":
+		return 2, nil
+	default:
+		return 0, fmt.Errorf("invalid urgency '%s'", urgency)
+	}
+}
+
+// parseHint converts a notify-send style TYPE:NAME:VALUE hint
+func parseHint(hint string) (string, dbus.Variant, error) {
+	parts := strings.SplitN(hint, ":", 3)
+	if len(parts) != 3 {
+		return "", dbus.Variant{}, fmt.Errorf("invalid hint '%s', expected TYPE:NAME:VALUE", hint)
+	}
+	kind, name, value := parts[0], parts[1], parts[2]
+
+	switch kind {
+	case "string":
+		return name, dbus.MakeVariant(value), nil
+	case "int":
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return "", dbus.Variant{}, fmt.Errorf("invalid int hint '%s': %w", hint, err)
+		}
+		return name, dbus.MakeVariant(int32(n)), nil
+	case "double":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", dbus.Variant{}, fmt.Errorf("invalid double hint '%s': %w", hint, err)
+		}
+		return name, dbus.MakeVariant(f), nil
+	case "byte":
+		b, err := strconv.ParseUint(value, 10, 8)
+		if err != nil {
+			return "", dbus.Variant{}, fmt.Errorf("invalid byte hint '%s': %w", hint, err)
+		}
+		return name, dbus.MakeVariant(uint8(b)), nil
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", dbus.Variant{}, fmt.Errorf("invalid boolean hint '%s': %w", hint, err)
+		}
+		return name, dbus.MakeVariant(b), nil
+	default:
+		return "", dbus.Variant{}, fmt.Errorf("unknown hint type '%s'", kind)
+	}
+}
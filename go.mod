@@ -5,4 +5,7 @@ go 1.24.4
 require (
 	github.com/godbus/dbus/v5 v5.1.0
 	github.com/pelletier/go-toml/v2 v2.2.4
+	go.etcd.io/bbolt v1.4.3
 )
+
+require golang.org/x/sys v0.29.0 // indirect
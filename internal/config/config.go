@@ -1,9 +1,15 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/pelletier/go-toml/v2"
 )
@@ -11,27 +17,172 @@ import (
 var DefaultConfig = Config{
 	EwwDefaultNotificationKey: nil,
 	EwwWindow:                 nil,
+	CenterWindow:              nil,
+	EwwConfigDir:              nil,
+	EwwBinary:                 "eww",
+	EwwAutostart:              false,
 	MaxNotifications:          0,
+	MaxNotificationsPerApp:    0,
+	HistorySize:               50,
+	IconTheme:                 "",
+	IconSize:                  48,
 	NotificationOrientation:   Vertical,
+	DisplayMode:               DisplayList,
+	Order:                     OrderOldestFirst,
+	BodyMarkup:                MarkupStrip,
+	CompactAfter:              0,
+	DuplicateWindow:           Seconds(10),
+	ProgressTick:              0,
+	StablePositions:           false,
+	WorkspaceRouting:          RoutingOff,
+	DBusMode:                  DBusOwner,
+	OnNameLost:                NameLostExit,
+	LogLevel:                  LogWarn,
+	PortalBackend:             false,
+	PersistState:              false,
+	DisableIPC:                false,
+	IPCSocket:                 nil,
+	AllowedClasses:            nil,
+	AppAliases:                nil,
+	HistoryStore: HistoryStore{
+		Enabled:    false,
+		Path:       "",
+		MaxAge:     Duration(30 * 24 * time.Hour),
+		MaxEntries: 10000,
+	},
+	Metrics: Metrics{
+		Listen: "",
+	},
+	LogFile: LogFile{
+		Enabled:    false,
+		Path:       "",
+		MaxSize:    10,
+		MaxBackups: 3,
+	},
 	Timeout: Timeout{
 		ByUrgency: TimeoutByUrgency{
-			Low:      5,
-			Normal:   10,
+			Low:      Seconds(5),
+			Normal:   Seconds(10),
 			Critical: 0,
 		},
+		Client: ClientTimeoutByUrgency{
+			Low:      ClientHonor,
+			Normal:   ClientHonor,
+			Critical: ClientHonor,
+		},
+	},
+	Actions: Actions{
+		Max:             0,
+		DefaultPosition: DefaultKeep,
+		HiddenByApp:     nil,
+		InjectDismiss:   false,
+		DismissLabel:    "Dismiss",
+		InjectExtend:    false,
+		ExtendLabel:     "Keep",
+		ExtendBy:        30,
+		InjectLinks:     false,
+		OpenCommand:     []string{"xdg-open"},
+		CloseOnAction:   true,
+	},
+	EwwWatchdog: EwwWatchdog{
+		MaxFailures:   5,
+		ProbeInterval: 30,
+	},
+	ReplaceStorm: ReplaceStorm{
+		MaxPerSecond: 200,
+		Notify:       false,
+	},
+	SuppressedSummary: SuppressedSummary{
+		Interval: 0,
 	},
+	Dnd: Dnd{
+		CriticalBypass: true,
+		OnDisable:      DndFlush,
+	},
+	Sound: Sound{
+		Enabled:     false,
+		FileCommand: []string{"paplay"},
+		NameCommand: []string{"canberra-gtk-play", "-i"},
+		ByUrgency: SoundByUrgency{
+			Low:      "",
+			Normal:   "message-new-instant",
+			Critical: "dialog-warning",
+		},
+	},
+	Animation: Animation{
+		RevealDuration:    200,
+		DismissDuration:   200,
+		RevealTransition:  SlideDown,
+		DismissTransition: SlideUp,
+	},
+	Hooks: Hooks{
+		Notify: defaultHook,
+		Close:  defaultHook,
+		Expire: defaultHook,
+		Action: defaultHook,
+	},
+}
+
+var defaultHook = Hook{
+	Command:       nil,
+	Timeout:       Seconds(10),
+	MaxConcurrent: 4,
 }
 
 type ConfigFile struct {
 	Config Config `toml:"config"`
+	Rules  []Rule `toml:"rule"`
 }
 
 type Config struct {
-	EwwDefaultNotificationKey *string     `toml:"eww-default-notification-key"`
-	EwwWindow                 *string     `toml:"eww-window"`
-	MaxNotifications          uint32      `toml:"max-notifications"`
-	NotificationOrientation   Orientation `toml:"notification-orientation"`
-	Timeout                   Timeout     `toml:"timeout"`
+	EwwDefaultNotificationKey *string                     `toml:"eww-default-notification-key"`
+	EwwWindow                 *string                     `toml:"eww-window"`
+	CenterWindow              *string                     `toml:"center-window"`
+	EwwConfigDir              *string                     `toml:"eww-config-dir"`
+	EwwBinary                 string                      `toml:"eww-binary"`
+	EwwArgs                   []string                    `toml:"eww-args"`
+	EwwAutostart              bool                        `toml:"eww-autostart"`
+	MaxNotifications          uint32                      `toml:"max-notifications"`
+	MaxNotificationsPerApp    uint32                      `toml:"max-notifications-per-app"`
+	HistorySize               uint32                      `toml:"history-size"`
+	IconTheme                 string                      `toml:"icon-theme"`
+	IconSize                  uint32                      `toml:"icon-size"`
+	NotificationOrientation   Orientation                 `toml:"notification-orientation"`
+	DisplayMode               DisplayMode                 `toml:"display-mode"`
+	Order                     Order                       `toml:"order"`
+	BodyMarkup                BodyMarkup                  `toml:"body-markup"`
+	CompactAfter              uint32                      `toml:"compact-after"`
+	DuplicateWindow           Duration                    `toml:"duplicate-window"`
+	ProgressTick              uint32                      `toml:"progress-tick"`
+	StablePositions           bool                        `toml:"stable-positions"`
+	WorkspaceRouting          WorkspaceRouting            `toml:"workspace-routing"`
+	DBusMode                  DBusMode                    `toml:"dbus-mode"`
+	OnNameLost                NameLost                    `toml:"on-name-lost"`
+	LogLevel                  LogLevel                    `toml:"log-level"`
+	PortalBackend             bool                        `toml:"portal-backend"`
+	PersistState              bool                        `toml:"persist-state"`
+	HistoryStore              HistoryStore                `toml:"history-store"`
+	LogFile                   LogFile                     `toml:"log-file"`
+	Metrics                   Metrics                     `toml:"metrics"`
+	DisableIPC                bool                        `toml:"disable-ipc"`
+	IPCSocket                 *string                     `toml:"ipc-socket"`
+	Timeout                   Timeout                     `toml:"timeout"`
+	AllowedClasses            []string                    `toml:"allowed-classes"`
+	AppAliases                map[string]string           `toml:"app-aliases"`
+	AppFilter                 AppFilter                   `toml:"app-filter"`
+	Types                     map[string]NotificationType `toml:"types"`
+	Categories                map[string]NotificationType `toml:"categories"`
+	Animation                 Animation                   `toml:"animation"`
+	Actions                   Actions                     `toml:"actions"`
+	EwwWatchdog               EwwWatchdog                 `toml:"eww-watchdog"`
+	ReplaceStorm              ReplaceStorm                `toml:"replace-storm"`
+	SuppressedSummary         SuppressedSummary           `toml:"suppressed-summary"`
+	Dnd                       Dnd                         `toml:"dnd"`
+	Sound                     Sound                       `toml:"sound"`
+	Hooks                     Hooks                       `toml:"hooks"`
+
+	// Rules come from the top level [[rule]] tables
+	Rules []Rule `toml:"-"`
 }
 
 type Orientation string
@@ -41,14 +192,435 @@ const (
 	Vertical   Orientation = "v"
 )
 
+// DisplayMode controls how the notification list is laid out
+type DisplayMode string
+
+const (
+	// DisplayList renders every notification as its own widget
+	DisplayList DisplayMode = "list"
+	// DisplayStacked collapses consecutive notifications from the same app
+	// into a single widget showing the newest one
+	DisplayStacked DisplayMode = "stacked"
+	// DisplayGrouped collapses every notification from the same app into
+	// one group entry that can be expanded to show them all
+	DisplayGrouped DisplayMode = "grouped"
+)
+
+// Order controls which notification is shown first
+type Order string
+
+const (
+	OrderOldestFirst Order = "oldest-first"
+	OrderNewestFirst Order = "newest-first"
+	// OrderUrgency puts critical notifications first, then normal and low
+	// ones, newest first within each urgency
+	OrderUrgency Order = "urgency"
+)
+
+func (o *Order) UnmarshalText(text []byte) error {
+	switch order := Order(text); order {
+	case OrderOldestFirst, OrderNewestFirst, OrderUrgency:
+		*o = order
+	default:
+		return fmt.Errorf("unknown order %q", string(text))
+	}
+	return nil
+}
+
+// BodyMarkup controls what happens to markup in notification bodies
+type BodyMarkup string
+
+const (
+	// MarkupStrip removes all tags, the widget gets plain text
+	MarkupStrip BodyMarkup = "strip"
+	// MarkupTranslate keeps <b>, <i>, <u> and <a> as well formed Pango
+	// markup for a label with :markup
+	MarkupTranslate BodyMarkup = "translate"
+	// MarkupRaw passes the body through as the client sent it
+	MarkupRaw BodyMarkup = "raw"
+)
+
+func (m *BodyMarkup) UnmarshalText(text []byte) error {
+	switch mode := BodyMarkup(text); mode {
+	case MarkupStrip, MarkupTranslate, MarkupRaw:
+		*m = mode
+	default:
+		return fmt.Errorf("unknown body markup mode %q", string(text))
+	}
+	return nil
+}
+
+// WorkspaceRouting selects the compositor used to find which output a
+// notification's app lives on
+type WorkspaceRouting string
+
+const (
+	RoutingOff      WorkspaceRouting = "off"
+	RoutingHyprland WorkspaceRouting = "hyprland"
+	RoutingSway     WorkspaceRouting = "sway"
+)
+
+func (r *WorkspaceRouting) UnmarshalText(text []byte) error {
+	switch routing := WorkspaceRouting(text); routing {
+	case RoutingOff, RoutingHyprland, RoutingSway:
+		*r = routing
+	default:
+		return fmt.Errorf("unknown workspace routing %q", string(text))
+	}
+	return nil
+}
+
+// DBusMode selects how an instance takes part in the session bus
+type DBusMode string
+
+const (
+	// DBusOwner owns org.freedesktop.Notifications and serves clients
+	DBusOwner DBusMode = "owner"
+	// DBusMonitor mirrors the notifications sent to the owning instance
+	DBusMonitor DBusMode = "monitor"
+)
+
+func (m *DBusMode) UnmarshalText(text []byte) error {
+	switch mode := DBusMode(text); mode {
+	case DBusOwner, DBusMonitor:
+		*m = mode
+	default:
+		return fmt.Errorf("unknown dbus mode %q", string(text))
+	}
+	return nil
+}
+
+// HistoryStore keeps every closed notification on disk for later queries,
+// unlike the in-memory history it survives restarts
+type HistoryStore struct {
+	Enabled bool `toml:"enabled"`
+	// Path defaults to $XDG_STATE_HOME/end/history.db, a bbolt database
+	Path string `toml:"path"`
+	// MaxAge drops older entries, 0 keeps them forever
+	MaxAge Duration `toml:"max-age"`
+	// MaxEntries keeps only the newest entries, 0 means no limit
+	MaxEntries uint32 `toml:"max-entries"`
+}
+
+// LogFile writes the daemon's logs to a file that is rotated by size, the
+// -log-file flag overrides enabled and path
+type LogFile struct {
+	Enabled bool `toml:"enabled"`
+	// Path defaults to $XDG_STATE_HOME/end/end.log
+	Path string `toml:"path"`
+	// MaxSize in MiB rotates the file once reached, 0 leaves rotation to
+	// logrotate and SIGUSR1
+	MaxSize uint32 `toml:"max-size"`
+	// MaxBackups is how many rotated files are kept, as end.log.1 onwards
+	MaxBackups uint32 `toml:"max-backups"`
+}
+
+// Metrics exposes the daemon's counters, the stats command works without it
+type Metrics struct {
+	// Listen is the address serving /metrics in the Prometheus text format,
+	// for example "127.0.0.1:9469", empty disables the endpoint
+	Listen string `toml:"listen"`
+}
+
+// NameLost decides what an owning instance does when another daemon takes
+// over org.freedesktop.Notifications
+type NameLost string
+
+const (
+	// NameLostExit shuts the daemon down
+	NameLostExit NameLost = "exit"
+	// NameLostQueue waits in the bus queue and serves again once the other
+	// daemon releases the name
+	NameLostQueue NameLost = "queue"
+)
+
+func (n *NameLost) UnmarshalText(text []byte) error {
+	switch mode := NameLost(text); mode {
+	case NameLostExit, NameLostQueue:
+		*n = mode
+	default:
+		return fmt.Errorf("unknown on-name-lost action %q", string(text))
+	}
+	return nil
+}
+
+// LogLevel is the least severe message the daemon logs
+type LogLevel string
+
+const (
+	LogError LogLevel = "error"
+	LogWarn  LogLevel = "warn"
+	LogInfo  LogLevel = "info"
+	LogDebug LogLevel = "debug"
+)
+
+func (l *LogLevel) UnmarshalText(text []byte) error {
+	switch level := LogLevel(text); level {
+	case LogError, LogWarn, LogInfo, LogDebug:
+		*l = level
+	default:
+		return fmt.Errorf("unknown log level %q", string(text))
+	}
+	return nil
+}
+
 type TimeoutByUrgency struct {
-	Low      uint32 `toml:"low"`
-	Normal   uint32 `toml:"normal"`
-	Critical uint32 `toml:"critical"`
+	Low      Duration `toml:"low"`
+	Normal   Duration `toml:"normal"`
+	Critical Duration `toml:"critical"`
+}
+
+// ClientTimeout decides what happens to the expire_timeout a client passes
+// to Notify
+type ClientTimeout string
+
+const (
+	// ClientHonor uses the client's timeout as is
+	ClientHonor ClientTimeout = "honor"
+	// ClientClamp caps the client's timeout at the urgency timeout
+	ClientClamp ClientTimeout = "clamp"
+	// ClientIgnore always uses the urgency timeout
+	ClientIgnore ClientTimeout = "ignore"
+)
+
+func (c *ClientTimeout) UnmarshalText(text []byte) error {
+	switch mode := ClientTimeout(text); mode {
+	case ClientHonor, ClientClamp, ClientIgnore:
+		*c = mode
+	default:
+		return fmt.Errorf("unknown client timeout mode %q", string(text))
+	}
+	return nil
+}
+
+type ClientTimeoutByUrgency struct {
+	Low      ClientTimeout `toml:"low"`
+	Normal   ClientTimeout `toml:"normal"`
+	Critical ClientTimeout `toml:"critical"`
 }
 
 type Timeout struct {
-	ByUrgency TimeoutByUrgency `toml:"urgency"`
+	ByUrgency TimeoutByUrgency       `toml:"urgency"`
+	Client    ClientTimeoutByUrgency `toml:"client"`
+}
+
+// DefaultPosition controls where the "default" action is placed
+type DefaultPosition string
+
+const (
+	DefaultKeep  DefaultPosition = "keep"
+	DefaultFirst DefaultPosition = "first"
+	DefaultLast  DefaultPosition = "last"
+)
+
+// Actions controls which action buttons are exported to widgets
+type Actions struct {
+	// Max caps the number of exported actions, 0 means no limit
+	Max             uint32              `toml:"max"`
+	DefaultPosition DefaultPosition     `toml:"default-position"`
+	HiddenByApp     map[string][]string `toml:"hidden"`
+	// InjectDismiss appends a synthetic __dismiss action to every notification
+	InjectDismiss bool   `toml:"inject-dismiss"`
+	DismissLabel  string `toml:"dismiss-label"`
+	// InjectExtend appends a synthetic __extend action to timed notifications
+	// that keeps them ExtendBy seconds longer
+	InjectExtend bool   `toml:"inject-extend"`
+	ExtendLabel  string `toml:"extend-label"`
+	ExtendBy     uint32 `toml:"extend-by"`
+	// InjectLinks appends a synthetic __open-url:N action for every link in
+	// the body, opened with OpenCommand
+	InjectLinks bool     `toml:"inject-links"`
+	OpenCommand []string `toml:"open-command"`
+	// ActivationCommand prints an xdg-activation token that is sent to the
+	// application before an action is invoked, empty disables tokens
+	ActivationCommand []string `toml:"activation-command"`
+	// CloseOnAction dismisses a notification once an action was invoked,
+	// notifications with the resident hint always stay
+	CloseOnAction bool `toml:"close-on-action"`
+}
+
+// IsHidden reports whether an action key is hidden for the given app
+func (a Actions) IsHidden(appName, key string) bool {
+	return slices.Contains(a.HiddenByApp[appName], key)
+}
+
+func (p *DefaultPosition) UnmarshalText(text []byte) error {
+	switch pos := DefaultPosition(text); pos {
+	case DefaultKeep, DefaultFirst, DefaultLast:
+		*p = pos
+	default:
+		return fmt.Errorf("unknown default action position %q", string(text))
+	}
+	return nil
+}
+
+// EwwWatchdog controls when eww is considered down
+type EwwWatchdog struct {
+	// MaxFailures consecutive failed eww commands enter degraded mode,
+	// 0 disables the watchdog
+	MaxFailures uint32 `toml:"max-failures"`
+	// ProbeInterval is the number of seconds between recovery probes
+	ProbeInterval uint32 `toml:"probe-interval"`
+}
+
+// ReplaceStorm limits how fast an app may replace its notifications
+type ReplaceStorm struct {
+	// MaxPerSecond replaces per app before throttling, 0 disables the check
+	MaxPerSecond uint32 `toml:"max-per-second"`
+	// Notify posts a warning notification when an app starts storming
+	Notify bool `toml:"notify"`
+}
+
+// SuppressedSummary controls the periodic "N notifications suppressed"
+// popup posted when notifications are dropped
+type SuppressedSummary struct {
+	// Interval in seconds between summaries, 0 disables them
+	Interval uint32 `toml:"interval"`
+}
+
+// DndDisableAction decides what happens to notifications queued during
+// Do-Not-Disturb once it is turned off
+type DndDisableAction string
+
+const (
+	// DndFlush shows the queued notifications
+	DndFlush DndDisableAction = "flush"
+	// DndToHistory moves them straight to history
+	DndToHistory DndDisableAction = "history"
+)
+
+func (a *DndDisableAction) UnmarshalText(text []byte) error {
+	switch action := DndDisableAction(text); action {
+	case DndFlush, DndToHistory:
+		*a = action
+	default:
+		return fmt.Errorf("unknown dnd on-disable action %q", string(text))
+	}
+	return nil
+}
+
+// Dnd configures Do-Not-Disturb mode
+type Dnd struct {
+	// CriticalBypass lets critical notifications through while DND is on
+	CriticalBypass bool             `toml:"critical-bypass"`
+	OnDisable      DndDisableAction `toml:"on-disable"`
+	Schedule       DndSchedule      `toml:"schedule"`
+}
+
+// SoundByUrgency holds the sound played for each urgency, a sound theme
+// name or an absolute path to a file, empty for silence
+type SoundByUrgency struct {
+	Low      string `toml:"low"`
+	Normal   string `toml:"normal"`
+	Critical string `toml:"critical"`
+}
+
+// Sound configures notification sounds
+type Sound struct {
+	Enabled bool `toml:"enabled"`
+	// FileCommand plays a sound file, the path is appended
+	FileCommand []string `toml:"file-command"`
+	// NameCommand plays a sound theme sound, the name is appended
+	NameCommand []string       `toml:"name-command"`
+	ByUrgency   SoundByUrgency `toml:"urgency"`
+}
+
+// Hooks run external commands on notification events, with the
+// notification in END_* environment variables
+type Hooks struct {
+	Notify Hook `toml:"notify"`
+	Close  Hook `toml:"close"`
+	Expire Hook `toml:"expire"`
+	Action Hook `toml:"action"`
+}
+
+// Hook is a command run on one kind of event
+type Hook struct {
+	Command []string `toml:"command"`
+	// Timeout kills a hook that runs longer, 0 lets it run forever
+	Timeout Duration `toml:"timeout"`
+	// MaxConcurrent skips the hook while this many runs are still going,
+	// 0 means no limit
+	MaxConcurrent uint32 `toml:"max-concurrent"`
+}
+
+// NotificationType configures notifications carrying a matching end-type
+// or type hint
+type NotificationType struct {
+	// Widget renders the notification instead of the default widget
+	Widget string `toml:"widget"`
+	// Timeout replaces the urgency based timeout
+	Timeout *Duration `toml:"timeout"`
+	// Sound replaces the urgency sound, a sound theme name or a path
+	Sound string `toml:"sound"`
+}
+
+// Category returns the config for a category hint such as "email.arrived",
+// falling back to its class ("email") when the full name isn't configured
+func (c *Config) Category(category string) (NotificationType, bool) {
+	if category == "" {
+		return NotificationType{}, false
+	}
+	if categoryCfg, ok := c.Categories[category]; ok {
+		return categoryCfg, true
+	}
+	class, _, found := strings.Cut(category, ".")
+	if !found {
+		return NotificationType{}, false
+	}
+	categoryCfg, ok := c.Categories[class]
+	return categoryCfg, ok
+}
+
+// defaultTypes returns the types known without any configuration
+func defaultTypes() map[string]NotificationType {
+	batteryTimeout := Seconds(10)
+	return map[string]NotificationType{
+		"battery": {Widget: "battery-notification", Timeout: &batteryTimeout},
+	}
+}
+
+// Transition mirrors the transition names accepted by eww revealers
+type Transition string
+
+const (
+	SlideRight   Transition = "slideright"
+	SlideLeft    Transition = "slideleft"
+	SlideUp      Transition = "slideup"
+	SlideDown    Transition = "slidedown"
+	Crossfade    Transition = "crossfade"
+	NoTransition Transition = "none"
+)
+
+// Animation durations are in milliseconds
+type Animation struct {
+	RevealDuration    uint32     `toml:"reveal-duration"`
+	DismissDuration   uint32     `toml:"dismiss-duration"`
+	RevealTransition  Transition `toml:"reveal-transition"`
+	DismissTransition Transition `toml:"dismiss-transition"`
+	// ClosingDuration keeps closed notifications on screen with state
+	// "closing" this long, 0 removes them at once
+	ClosingDuration uint32 `toml:"closing-duration"`
+}
+
+func (t *Transition) UnmarshalText(text []byte) error {
+	switch tr := Transition(text); tr {
+	case SlideRight, SlideLeft, SlideUp, SlideDown, Crossfade, NoTransition:
+		*t = tr
+	default:
+		return fmt.Errorf("unknown transition %q", string(text))
+	}
+	return nil
+}
+
+func (m *DisplayMode) UnmarshalText(text []byte) error {
+	switch mode := DisplayMode(text); mode {
+	case DisplayList, DisplayStacked, DisplayGrouped:
+		*m = mode
+	default:
+		return fmt.Errorf("unknown display mode %q", string(text))
+	}
+	return nil
 }
 
 func (o *Orientation) UnmarshalText(text []byte) error {
@@ -58,11 +630,31 @@ func (o *Orientation) UnmarshalText(text []byte) error {
 	case "v":
 		*o = Vertical
 	default:
-		*o = Vertical
+		return fmt.Errorf("invalid orientation %q, expected \"h\" or \"v\"", text)
 	}
 	return nil
 }
 
+// IsClassAllowed reports whether a class requested through the end-class
+// hint may be passed on to eww
+func (c Config) IsClassAllowed(class string) bool {
+	return slices.Contains(c.AllowedClasses, class)
+}
+
+// NormalizeAppName maps an app name through the app-aliases table, exact
+// matches win over case-insensitive ones
+func (c Config) NormalizeAppName(appName string) string {
+	if alias, ok := c.AppAliases[appName]; ok {
+		return alias
+	}
+	for name, alias := range c.AppAliases {
+		if strings.EqualFold(name, appName) {
+			return alias
+		}
+	}
+	return appName
+}
+
 func GetConfigDir() (string, error) {
 	configDir := os.Getenv("XDG_CONFIG_HOME")
 	if configDir == "" {
@@ -76,44 +668,105 @@ func GetConfigDir() (string, error) {
 }
 
 func LoadConfig() (*Config, error) {
-	configDir, err := GetConfigDir()
+	configFilePath, err := GetConfigPath()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get config directory: %w", err)
+		return nil, err
 	}
 
-	configFilePath := filepath.Join(configDir, "end", "config.toml")
+	var configFile ConfigFile
+
+	configFile.Config = DefaultConfig
 
+	// Without a file the defaults still take environment overrides
 	if _, err := os.Stat(configFilePath); os.IsNotExist(err) {
-		fmt.Printf("Could not find config file! Should be at %s\n", configFilePath)
-		return nil, nil
+		slog.Warn("Could not find config file, using defaults", "path", configFilePath)
+	} else if err := decodeConfigFile(configFilePath, &configFile); err != nil {
+		return nil, err
 	}
 
-	configData, err := os.ReadFile(configFilePath)
+	dropIns, err := dropInPaths(configFilePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return nil, err
+	}
+	for _, path := range dropIns {
+		if err := decodeConfigFile(path, &configFile); err != nil {
+			return nil, err
+		}
 	}
 
-	var configFile ConfigFile
-
-	configFile.Config = DefaultConfig
+	mergedConfig := mergeWithDefaults(configFile.Config)
+	mergedConfig.Rules = configFile.Rules
 
-	if err := toml.Unmarshal(configData, &configFile); err != nil {
-		fmt.Println("There were errors in your config.toml!")
-		fmt.Printf("Error: %v\n", err)
-		return nil, nil
+	if err := mergedConfig.applyEnvOverrides(); err != nil {
+		return nil, fmt.Errorf("invalid environment override: %w", err)
 	}
 
-	mergedConfig := mergeWithDefaults(configFile.Config)
 	return &mergedConfig, nil
 }
 
+// decodeConfigFile decodes path over configFile, keys it sets replace the
+// current values while its rules are added after the existing ones
+func decodeConfigFile(path string, configFile *ConfigFile) error {
+	configData, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	rules := configFile.Rules
+	configFile.Rules = nil
+
+	decoder := toml.NewDecoder(bytes.NewReader(configData))
+	decoder.DisallowUnknownFields()
+	decoder.EnableUnmarshalerInterface()
+	if err := decoder.Decode(configFile); err != nil {
+		return describeDecodeError(path, err)
+	}
+
+	configFile.Rules = append(rules, configFile.Rules...)
+	return nil
+}
+
+// dropInPaths returns the *.toml files in the config.d directory next to
+// the config file, in the lexical order they are applied in
+func dropInPaths(configFilePath string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(filepath.Dir(configFilePath), "config.d", "*.toml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config drop-ins: %w", err)
+	}
+	return paths, nil
+}
+
+// describeDecodeError points at the key and line a TOML error comes from
+func describeDecodeError(path string, err error) error {
+	var strictErr *toml.StrictMissingError
+	if errors.As(err, &strictErr) {
+		problems := make([]string, 0, len(strictErr.Errors))
+		for _, keyErr := range strictErr.Errors {
+			row, _ := keyErr.Position()
+			problems = append(problems, fmt.Sprintf("%s:%d: unknown key %q", path, row, strings.Join(keyErr.Key(), ".")))
+		}
+		return fmt.Errorf("invalid config:\n%s", strings.Join(problems, "\n"))
+	}
+
+	var decodeErr *toml.DecodeError
+	if errors.As(err, &decodeErr) {
+		row, column := decodeErr.Position()
+		if key := decodeErr.Key(); len(key) > 0 {
+			return fmt.Errorf("invalid config: %s:%d:%d: %s: %w", path, row, column, strings.Join(key, "."), err)
+		}
+		return fmt.Errorf("invalid config: %s:%d:%d: %w", path, row, column, err)
+	}
+
+	return fmt.Errorf("invalid config: %s: %w", path, err)
+}
+
 func mergeWithDefaults(cfg Config) Config {
 	result := cfg
 
 	if result.Timeout.ByUrgency.Low == 0 &&
 		result.Timeout.ByUrgency.Normal == 0 &&
 		result.Timeout.ByUrgency.Critical == 0 {
-		result.Timeout = DefaultConfig.Timeout
+		result.Timeout.ByUrgency = DefaultConfig.Timeout.ByUrgency
 	} else {
 		if result.Timeout.ByUrgency.Low == 0 {
 			result.Timeout.ByUrgency.Low = DefaultConfig.Timeout.ByUrgency.Low
@@ -123,9 +776,104 @@ func mergeWithDefaults(cfg Config) Config {
 		}
 	}
 
+	if result.Timeout.Client.Low == "" {
+		result.Timeout.Client.Low = DefaultConfig.Timeout.Client.Low
+	}
+	if result.Timeout.Client.Normal == "" {
+		result.Timeout.Client.Normal = DefaultConfig.Timeout.Client.Normal
+	}
+	if result.Timeout.Client.Critical == "" {
+		result.Timeout.Client.Critical = DefaultConfig.Timeout.Client.Critical
+	}
+
 	if result.NotificationOrientation == "" {
 		result.NotificationOrientation = DefaultConfig.NotificationOrientation
 	}
 
+	if result.BodyMarkup == "" {
+		result.BodyMarkup = DefaultConfig.BodyMarkup
+	}
+
+	if result.WorkspaceRouting == "" {
+		result.WorkspaceRouting = DefaultConfig.WorkspaceRouting
+	}
+
+	if result.DBusMode == "" {
+		result.DBusMode = DefaultConfig.DBusMode
+	}
+
+	if result.OnNameLost == "" {
+		result.OnNameLost = DefaultConfig.OnNameLost
+	}
+
+	if result.LogLevel == "" {
+		result.LogLevel = DefaultConfig.LogLevel
+	}
+
+	// Built-in types stay available unless the config redefines them
+	for name, notificationType := range defaultTypes() {
+		if _, exists := result.Types[name]; !exists {
+			if result.Types == nil {
+				result.Types = make(map[string]NotificationType)
+			}
+			result.Types[name] = notificationType
+		}
+	}
+
+	if len(result.Actions.OpenCommand) == 0 {
+		result.Actions.OpenCommand = DefaultConfig.Actions.OpenCommand
+	}
+
+	if len(result.Sound.FileCommand) == 0 {
+		result.Sound.FileCommand = DefaultConfig.Sound.FileCommand
+	}
+	if len(result.Sound.NameCommand) == 0 {
+		result.Sound.NameCommand = DefaultConfig.Sound.NameCommand
+	}
+
+	if result.IconSize == 0 {
+		result.IconSize = DefaultConfig.IconSize
+	}
+
+	if result.EwwBinary == "" {
+		result.EwwBinary = DefaultConfig.EwwBinary
+	}
+
+	if result.DisplayMode == "" {
+		result.DisplayMode = DefaultConfig.DisplayMode
+	}
+
+	if result.Order == "" {
+		result.Order = DefaultConfig.Order
+	}
+
+	if result.Actions.DefaultPosition == "" {
+		result.Actions.DefaultPosition = DefaultConfig.Actions.DefaultPosition
+	}
+	if result.Actions.DismissLabel == "" {
+		result.Actions.DismissLabel = DefaultConfig.Actions.DismissLabel
+	}
+	if result.Actions.ExtendLabel == "" {
+		result.Actions.ExtendLabel = DefaultConfig.Actions.ExtendLabel
+	}
+	if result.Actions.ExtendBy == 0 {
+		result.Actions.ExtendBy = DefaultConfig.Actions.ExtendBy
+	}
+
+	if result.EwwWatchdog.ProbeInterval == 0 {
+		result.EwwWatchdog.ProbeInterval = DefaultConfig.EwwWatchdog.ProbeInterval
+	}
+
+	if result.Dnd.OnDisable == "" {
+		result.Dnd.OnDisable = DefaultConfig.Dnd.OnDisable
+	}
+
+	if result.Animation.RevealTransition == "" {
+		result.Animation.RevealTransition = DefaultConfig.Animation.RevealTransition
+	}
+	if result.Animation.DismissTransition == "" {
+		result.Animation.DismissTransition = DefaultConfig.Animation.DismissTransition
+	}
+
 	return result
 }
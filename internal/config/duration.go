@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+// Duration is a timeout written as seconds (5, 0.75) or as a Go duration
+// string ("750ms", "1m30s")
+type Duration time.Duration
+
+// Seconds builds a Duration from whole seconds
+func Seconds(n uint32) Duration {
+	return Duration(time.Duration(n) * time.Second)
+}
+
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func (d *Duration) UnmarshalTOML(node *unstable.Node) error {
+	switch node.Kind {
+	case unstable.Integer, unstable.Float, unstable.String:
+		return d.UnmarshalText(node.Data)
+	default:
+		return fmt.Errorf("expected seconds or a duration string, got %s", node.Kind)
+	}
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	value := strings.ReplaceAll(strings.TrimSpace(string(text)), "_", "")
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds < 0 {
+			return fmt.Errorf("duration %q must not be negative", string(text))
+		}
+		if math.IsNaN(seconds) || math.IsInf(seconds, 0) || seconds*float64(time.Second) >= math.MaxInt64 {
+			return fmt.Errorf("invalid duration %q, expected a finite number of seconds", string(text))
+		}
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q, expected seconds or e.g. \"750ms\"", string(text))
+	}
+	if parsed < 0 {
+		return fmt.Errorf("duration %q must not be negative", string(text))
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix starts every environment variable overriding a config key
+const EnvPrefix = "END_"
+
+// EnvName returns the environment variable overriding a config key, e.g.
+// END_EWW_WINDOW for eww-window and END_TIMEOUT_NORMAL for timeout.normal
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+}
+
+// applyEnvOverrides sets config keys from END_* environment variables.
+// Nested keys join their table names, so timeout.urgency.normal is read
+// from END_TIMEOUT_URGENCY_NORMAL, and the shorter runtime key names such
+// as END_TIMEOUT_NORMAL work as well. Lists are comma separated, string
+// maps use key=value pairs.
+func (c *Config) applyEnvOverrides() error {
+	if err := applyEnvToStruct(reflect.ValueOf(c).Elem(), ""); err != nil {
+		return err
+	}
+
+	for _, key := range RuntimeKeys {
+		if value, ok := os.LookupEnv(EnvName(key)); ok {
+			if err := c.SetValue(key, value); err != nil {
+				return fmt.Errorf("%s: %w", EnvName(key), err)
+			}
+		}
+	}
+	return nil
+}
+
+// applyEnvToStruct walks the toml tagged fields of v, prefix holds the
+// enclosing table names
+func applyEnvToStruct(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := range t.NumField() {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("toml"), ",")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		key := prefix + tag
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct && !isTextUnmarshaler(field) {
+			if err := applyEnvToStruct(field, key+"."); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := os.LookupEnv(EnvName(key))
+		if !ok {
+			continue
+		}
+		if err := setFromEnv(field, value); err != nil {
+			return fmt.Errorf("%s: %w", EnvName(key), err)
+		}
+	}
+	return nil
+}
+
+func isTextUnmarshaler(v reflect.Value) bool {
+	_, ok := v.Addr().Interface().(encoding.TextUnmarshaler)
+	return ok
+}
+
+// setFromEnv parses value into field according to the field's type
+func setFromEnv(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Pointer {
+		elem := reflect.New(field.Type().Elem())
+		if err := setFromEnv(elem.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(value))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("expected true or false: %w", err)
+		}
+		field.SetBool(b)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected a non-negative integer: %w", err)
+		}
+		field.SetUint(n)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected an integer: %w", err)
+		}
+		field.SetInt(n)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("cannot be set from the environment")
+		}
+		items := reflect.MakeSlice(field.Type(), 0, 0)
+		for _, item := range splitEnvList(value) {
+			elem := reflect.New(field.Type().Elem()).Elem()
+			if err := setFromEnv(elem, item); err != nil {
+				return err
+			}
+			items = reflect.Append(items, elem)
+		}
+		field.Set(items)
+	case reflect.Map:
+		if field.Type().Key().Kind() != reflect.String || field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("cannot be set from the environment")
+		}
+		entries := reflect.MakeMap(field.Type())
+		for _, item := range splitEnvList(value) {
+			k, v, ok := strings.Cut(item, "=")
+			if !ok {
+				return fmt.Errorf("expected key=value pairs, got %q", item)
+			}
+			entries.SetMapIndex(reflect.ValueOf(strings.TrimSpace(k)), reflect.ValueOf(strings.TrimSpace(v)))
+		}
+		field.Set(entries)
+	default:
+		return fmt.Errorf("cannot be set from the environment")
+	}
+	return nil
+}
+
+// splitEnvList splits a comma separated list, dropping empty items
+func splitEnvList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,73 @@
+package config
+
+import "fmt"
+
+// FilterAction is what happens to a notification from a filtered app
+type FilterAction string
+
+const (
+	FilterDrop    FilterAction = "drop"
+	FilterHistory FilterAction = "history"
+)
+
+func (a *FilterAction) UnmarshalText(text []byte) error {
+	switch action := FilterAction(text); action {
+	case FilterDrop, FilterHistory:
+		*a = action
+	default:
+		return fmt.Errorf("unknown filter action %q", string(text))
+	}
+	return nil
+}
+
+// AppMatch selects apps by exact name or by pattern
+type AppMatch struct {
+	Name    string   `toml:"name"`
+	Pattern *Pattern `toml:"pattern"`
+	// Action applies to blocked apps, empty drops the notification
+	Action FilterAction `toml:"action"`
+}
+
+func (m AppMatch) Matches(appName string) bool {
+	if m.Name != "" && m.Name == appName {
+		return true
+	}
+	return m.Pattern != nil && m.Pattern.MatchString(appName)
+}
+
+// AppFilter keeps notifications of unwanted apps off the screen
+type AppFilter struct {
+	Block []AppMatch `toml:"block"`
+	// AllowlistOnly shows only apps matching Allow, everything else is
+	// handled according to Unlisted
+	AllowlistOnly bool         `toml:"allowlist-only"`
+	Allow         []AppMatch   `toml:"allow"`
+	Unlisted      FilterAction `toml:"unlisted"`
+}
+
+// Check reports whether notifications of the app are filtered and what
+// happens to them
+func (f AppFilter) Check(appName string) (FilterAction, bool) {
+	for _, match := range f.Block {
+		if match.Matches(appName) {
+			return orDrop(match.Action), true
+		}
+	}
+
+	if !f.AllowlistOnly {
+		return "", false
+	}
+	for _, match := range f.Allow {
+		if match.Matches(appName) {
+			return "", false
+		}
+	}
+	return orDrop(f.Unlisted), true
+}
+
+func orDrop(action FilterAction) FilterAction {
+	if action == "" {
+		return FilterDrop
+	}
+	return action
+}
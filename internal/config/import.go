@@ -0,0 +1,644 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// ImportSource names a notification daemon whose config can be imported
+type ImportSource string
+
+const (
+	ImportDunst ImportSource = "dunst"
+	ImportMako  ImportSource = "mako"
+)
+
+func (s *ImportSource) UnmarshalText(text []byte) error {
+	switch source := ImportSource(text); source {
+	case ImportDunst, ImportMako:
+		*s = source
+	default:
+		return fmt.Errorf("unknown import source %q, expected dunst or mako", string(text))
+	}
+	return nil
+}
+
+// Imported is the part of a dunstrc or mako config that translates to an
+// end config, plus the settings that were left behind
+type Imported struct {
+	Config importedConfig
+	Rules  []importedRule
+	// Skipped lists the settings without an equivalent, by source line
+	Skipped []string
+}
+
+// importedConfig mirrors the Config fields an import can set, leaving
+// everything else out of the generated file
+type importedConfig struct {
+	MaxNotifications *uint32         `toml:"max-notifications,omitempty"`
+	HistorySize      *uint32         `toml:"history-size,omitempty"`
+	Order            Order           `toml:"order,omitempty"`
+	Timeout          importedTimeout `toml:"timeout,omitempty"`
+}
+
+type importedTimeout struct {
+	ByUrgency importedUrgencyTimeout `toml:"urgency,omitempty"`
+	Client    importedClientTimeout  `toml:"client,omitempty"`
+}
+
+type importedUrgencyTimeout struct {
+	Low      *Duration `toml:"low,omitempty"`
+	Normal   *Duration `toml:"normal,omitempty"`
+	Critical *Duration `toml:"critical,omitempty"`
+}
+
+type importedClientTimeout struct {
+	Low      ClientTimeout `toml:"low,omitempty"`
+	Normal   ClientTimeout `toml:"normal,omitempty"`
+	Critical ClientTimeout `toml:"critical,omitempty"`
+}
+
+// importedRule mirrors Rule with its patterns kept as plain strings
+type importedRule struct {
+	AppName     string    `toml:"app-name,omitempty"`
+	Summary     string    `toml:"summary,omitempty"`
+	Body        string    `toml:"body,omitempty"`
+	Urgency     Urgency   `toml:"urgency,omitempty"`
+	Category    string    `toml:"category,omitempty"`
+	Timeout     *Duration `toml:"timeout,omitempty"`
+	SetUrgency  Urgency   `toml:"set-urgency,omitempty"`
+	SkipDisplay bool      `toml:"skip-display,omitempty"`
+	HistoryOnly bool      `toml:"history-only,omitempty"`
+	Script      string    `toml:"script,omitempty"`
+}
+
+func (r importedRule) hasAction() bool {
+	return r.Timeout != nil || r.SetUrgency != "" || r.SkipDisplay || r.HistoryOnly || r.Script != ""
+}
+
+// Import translates a dunstrc or mako config read from r
+func Import(source ImportSource, r io.Reader) (*Imported, error) {
+	sections, err := parseINI(r)
+	if err != nil {
+		return nil, err
+	}
+
+	imported := &Imported{}
+	switch source {
+	case ImportDunst:
+		imported.importDunst(sections)
+	case ImportMako:
+		imported.importMako(sections)
+	default:
+		return nil, fmt.Errorf("unknown import source %q", source)
+	}
+	return imported, nil
+}
+
+// WriteTOML writes the imported settings as an end config.toml, listing the
+// skipped settings as comments at the top
+func (im *Imported) WriteTOML(w io.Writer, from string) error {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "# Imported from %s by eww-notify import-config\n", from)
+	if len(im.Skipped) > 0 {
+		b.WriteString("#\n# These settings have no equivalent and were left out:\n")
+		for _, skipped := range im.Skipped {
+			fmt.Fprintf(&b, "#   %s\n", skipped)
+		}
+	}
+	b.WriteString("\n")
+
+	file := struct {
+		Config importedConfig `toml:"config"`
+		Rules  []importedRule `toml:"rule,omitempty"`
+	}{im.Config, im.Rules}
+
+	data, err := toml.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to encode imported config: %w", err)
+	}
+	b.Write(data)
+
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+func (im *Imported) skip(key iniKey, reason string) {
+	entry := fmt.Sprintf("line %d: %s = %s", key.Line, key.Name, key.Value)
+	if reason != "" {
+		entry += " (" + reason + ")"
+	}
+	im.Skipped = append(im.Skipped, entry)
+}
+
+func (im *Imported) skipSection(section iniSection, reason string) {
+	im.Skipped = append(im.Skipped, fmt.Sprintf("line %d: [%s] (%s)", section.Line, section.Name, reason))
+}
+
+// setUrgencyTimeout sets the timeout for urgency. A low or normal timeout
+// of 0 falls back to the default, so it cannot mean "never expire" there.
+func (im *Imported) setUrgencyTimeout(key iniKey, urgency Urgency, timeout Duration) {
+	switch urgency {
+	case UrgencyLow, UrgencyNormal:
+		if timeout == 0 {
+			im.skip(key, "only critical notifications can never expire")
+			return
+		}
+		if urgency == UrgencyLow {
+			im.Config.Timeout.ByUrgency.Low = &timeout
+		} else {
+			im.Config.Timeout.ByUrgency.Normal = &timeout
+		}
+	case UrgencyCritical:
+		im.Config.Timeout.ByUrgency.Critical = &timeout
+	}
+}
+
+// clientTimeout returns the client timeout mode field for urgency
+func (im *Imported) clientTimeout(urgency Urgency) *ClientTimeout {
+	switch urgency {
+	case UrgencyLow:
+		return &im.Config.Timeout.Client.Low
+	case UrgencyCritical:
+		return &im.Config.Timeout.Client.Critical
+	default:
+		return &im.Config.Timeout.Client.Normal
+	}
+}
+
+var allUrgencies = []Urgency{UrgencyLow, UrgencyNormal, UrgencyCritical}
+
+// dunst
+
+var dunstUrgencySections = map[string]Urgency{
+	"urgency_low":      UrgencyLow,
+	"urgency_normal":   UrgencyNormal,
+	"urgency_critical": UrgencyCritical,
+}
+
+// dunstMatchers are the dunstrc keys that make a section a rule
+var dunstMatchers = []string{
+	"appname", "summary", "body", "category", "msg_urgency",
+	"desktop_entry", "stack_tag", "match_transient", "match_dbus_timeout",
+}
+
+func (im *Imported) importDunst(sections []iniSection) {
+	for _, section := range sections {
+		if urgency, ok := dunstUrgencySections[section.Name]; ok {
+			for _, key := range section.Keys {
+				if key.Name != "timeout" {
+					im.skip(key, "")
+					continue
+				}
+				timeout, err := parseImportDuration(key.Value, unitSeconds)
+				if err != nil {
+					im.skip(key, err.Error())
+					continue
+				}
+				im.setUrgencyTimeout(key, urgency, timeout)
+			}
+			continue
+		}
+
+		switch {
+		case section.Name == "global":
+			im.importDunstGlobal(section)
+		case section.hasAny(dunstMatchers):
+			im.importDunstRule(section)
+		default:
+			for _, key := range section.Keys {
+				im.skip(key, "")
+			}
+		}
+	}
+}
+
+func (im *Imported) importDunstGlobal(section iniSection) {
+	for _, key := range section.Keys {
+		switch key.Name {
+		case "notification_limit":
+			n, err := strconv.ParseUint(key.Value, 10, 32)
+			if err != nil {
+				im.skip(key, "not a number")
+				continue
+			}
+			limit := uint32(n)
+			im.Config.MaxNotifications = &limit
+		case "history_length":
+			n, err := strconv.ParseUint(key.Value, 10, 32)
+			if err != nil {
+				im.skip(key, "not a number")
+				continue
+			}
+			size := uint32(n)
+			im.Config.HistorySize = &size
+		case "sort":
+			switch strings.ToLower(key.Value) {
+			case "yes", "true", "urgency_descending":
+				im.Config.Order = OrderUrgency
+			case "no", "false", "id":
+				im.Config.Order = OrderOldestFirst
+			default:
+				im.skip(key, "")
+			}
+		default:
+			im.skip(key, "")
+		}
+	}
+}
+
+func (im *Imported) importDunstRule(section iniSection) {
+	var rule importedRule
+
+	for _, key := range section.Keys {
+		switch key.Name {
+		case "appname":
+			if isGlob(key.Value) {
+				im.skipSection(section, "appname wildcards are not supported")
+				return
+			}
+			rule.AppName = key.Value
+		case "category":
+			if isGlob(key.Value) {
+				im.skipSection(section, "category wildcards are not supported")
+				return
+			}
+			rule.Category = key.Value
+		case "summary":
+			rule.Summary = globToRegexp(key.Value)
+		case "body":
+			rule.Body = globToRegexp(key.Value)
+		case "msg_urgency":
+			if err := rule.Urgency.UnmarshalText([]byte(strings.ToLower(key.Value))); err != nil {
+				im.skipSection(section, err.Error())
+				return
+			}
+		case "desktop_entry", "stack_tag", "match_transient", "match_dbus_timeout":
+			im.skipSection(section, "matches on "+key.Name)
+			return
+		case "timeout":
+			timeout, err := parseImportDuration(key.Value, unitSeconds)
+			if err != nil {
+				im.skip(key, err.Error())
+				continue
+			}
+			rule.Timeout = &timeout
+		case "urgency":
+			if err := rule.SetUrgency.UnmarshalText([]byte(strings.ToLower(key.Value))); err != nil {
+				im.skip(key, err.Error())
+			}
+		case "skip_display":
+			// dunst still keeps these in the history
+			rule.HistoryOnly = parseImportBool(key.Value)
+		case "format":
+			// An empty format is the dunst idiom for dropping a notification
+			if key.Value != "" {
+				im.skip(key, "")
+				continue
+			}
+			rule.SkipDisplay = true
+		case "script":
+			rule.Script = key.Value
+		default:
+			im.skip(key, "")
+		}
+	}
+
+	if !rule.hasAction() {
+		im.skipSection(section, "nothing left to apply")
+		return
+	}
+	im.Rules = append(im.Rules, rule)
+}
+
+// isGlob reports whether value uses fnmatch wildcards
+func isGlob(value string) bool {
+	return strings.ContainsAny(value, "*?[")
+}
+
+// globToRegexp turns a dunst fnmatch pattern into an anchored regexp
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// mako
+
+func (im *Imported) importMako(sections []iniSection) {
+	for _, section := range sections {
+		if section.Name == "" {
+			im.importMakoGlobal(section)
+			continue
+		}
+
+		criteria, err := parseMakoCriteria(section.Name)
+		if err != nil {
+			im.skipSection(section, err.Error())
+			continue
+		}
+
+		// [urgency=x] on its own configures the urgency defaults
+		if len(criteria) == 1 && criteria[0].name == "urgency" {
+			var urgency Urgency
+			if err := urgency.UnmarshalText([]byte(criteria[0].value)); err != nil {
+				im.skipSection(section, err.Error())
+				continue
+			}
+			im.importMakoUrgency(section, urgency)
+			continue
+		}
+
+		im.importMakoRule(section, criteria)
+	}
+}
+
+func (im *Imported) importMakoGlobal(section iniSection) {
+	for _, key := range section.Keys {
+		switch key.Name {
+		case "default-timeout":
+			timeout, err := parseImportDuration(key.Value, unitMillis)
+			if err != nil {
+				im.skip(key, err.Error())
+				continue
+			}
+			for _, urgency := range allUrgencies {
+				im.setUrgencyTimeout(key, urgency, timeout)
+			}
+		case "ignore-timeout":
+			if parseImportBool(key.Value) {
+				for _, urgency := range allUrgencies {
+					*im.clientTimeout(urgency) = ClientIgnore
+				}
+			}
+		case "max-visible":
+			n, err := strconv.ParseInt(key.Value, 10, 64)
+			if err != nil {
+				im.skip(key, "not a number")
+				continue
+			}
+			limit := uint32(max(0, n))
+			im.Config.MaxNotifications = &limit
+		case "max-history":
+			n, err := strconv.ParseUint(key.Value, 10, 32)
+			if err != nil {
+				im.skip(key, "not a number")
+				continue
+			}
+			size := uint32(n)
+			im.Config.HistorySize = &size
+		case "sort":
+			switch key.Value {
+			case "+time":
+				im.Config.Order = OrderOldestFirst
+			case "-time":
+				im.Config.Order = OrderNewestFirst
+			case "-priority":
+				im.Config.Order = OrderUrgency
+			default:
+				im.skip(key, "")
+			}
+		default:
+			im.skip(key, "")
+		}
+	}
+}
+
+func (im *Imported) importMakoUrgency(section iniSection, urgency Urgency) {
+	for _, key := range section.Keys {
+		switch key.Name {
+		case "default-timeout":
+			timeout, err := parseImportDuration(key.Value, unitMillis)
+			if err != nil {
+				im.skip(key, err.Error())
+				continue
+			}
+			im.setUrgencyTimeout(key, urgency, timeout)
+		case "ignore-timeout":
+			if parseImportBool(key.Value) {
+				*im.clientTimeout(urgency) = ClientIgnore
+			} else {
+				*im.clientTimeout(urgency) = ClientHonor
+			}
+		default:
+			im.skip(key, "")
+		}
+	}
+}
+
+func (im *Imported) importMakoRule(section iniSection, criteria []makoCriterion) {
+	var rule importedRule
+
+	for _, criterion := range criteria {
+		switch criterion.name {
+		case "app-name":
+			rule.AppName = criterion.value
+		case "category":
+			rule.Category = criterion.value
+		case "summary":
+			rule.Summary = "^" + regexp.QuoteMeta(criterion.value) + "$"
+		case "summary~":
+			rule.Summary = criterion.value
+		case "body":
+			rule.Body = "^" + regexp.QuoteMeta(criterion.value) + "$"
+		case "body~":
+			rule.Body = criterion.value
+		case "urgency":
+			if err := rule.Urgency.UnmarshalText([]byte(criterion.value)); err != nil {
+				im.skipSection(section, err.Error())
+				return
+			}
+		default:
+			im.skipSection(section, "matches on "+criterion.name)
+			return
+		}
+	}
+
+	for _, key := range section.Keys {
+		switch key.Name {
+		case "default-timeout":
+			timeout, err := parseImportDuration(key.Value, unitMillis)
+			if err != nil {
+				im.skip(key, err.Error())
+				continue
+			}
+			rule.Timeout = &timeout
+		case "invisible":
+			rule.HistoryOnly = parseImportBool(key.Value)
+		case "on-notify":
+			command, ok := strings.CutPrefix(key.Value, "exec ")
+			if !ok || strings.ContainsAny(strings.TrimSpace(command), " \t") {
+				im.skip(key, "only a plain executable can become a rule script")
+				continue
+			}
+			rule.Script = strings.TrimSpace(command)
+		default:
+			im.skip(key, "")
+		}
+	}
+
+	if !rule.hasAction() {
+		im.skipSection(section, "nothing left to apply")
+		return
+	}
+	im.Rules = append(im.Rules, rule)
+}
+
+// makoCriterion is one name=value pair of a mako section header, a regexp
+// match keeps its trailing ~ in the name
+type makoCriterion struct {
+	name  string
+	value string
+}
+
+// parseMakoCriteria splits a section header such as
+// app-name=Spotify summary~="Now playing.*" into its criteria
+func parseMakoCriteria(header string) ([]makoCriterion, error) {
+	var criteria []makoCriterion
+
+	rest := strings.TrimSpace(header)
+	for rest != "" {
+		name, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			if strings.Contains(rest, " ") {
+				return nil, fmt.Errorf("invalid criteria %q", header)
+			}
+			// Bare boolean criteria such as "actionable"
+			criteria = append(criteria, makoCriterion{name: rest, value: "1"})
+			break
+		}
+
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote in %q", header)
+			}
+			rest = value[end+2:]
+			value = value[1 : end+1]
+		} else {
+			value, rest, _ = strings.Cut(value, " ")
+		}
+
+		criteria = append(criteria, makoCriterion{name: strings.TrimSpace(name), value: value})
+		rest = strings.TrimSpace(rest)
+	}
+
+	return criteria, nil
+}
+
+// ini
+
+// iniSection holds the keys of one [section], the keys before the first
+// header land in a section with an empty name
+type iniSection struct {
+	Name string
+	Line int
+	Keys []iniKey
+}
+
+type iniKey struct {
+	Name  string
+	Value string
+	Line  int
+}
+
+func (s iniSection) hasAny(names []string) bool {
+	for _, key := range s.Keys {
+		for _, name := range names {
+			if key.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseINI reads the key = value format shared by dunstrc and mako
+func parseINI(r io.Reader) ([]iniSection, error) {
+	sections := []iniSection{{}}
+	current := &sections[0]
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			sections = append(sections, iniSection{Name: strings.TrimSpace(line[1 : len(line)-1]), Line: lineNumber})
+			current = &sections[len(sections)-1]
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value, got %q", lineNumber, line)
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+			value = value[1 : len(value)-1]
+		}
+
+		current.Keys = append(current.Keys, iniKey{
+			Name:  strings.TrimSpace(name),
+			Value: value,
+			Line:  lineNumber,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	return sections, nil
+}
+
+// Bare numbers are seconds in dunstrc and milliseconds in mako
+const (
+	unitSeconds = "s"
+	unitMillis  = "ms"
+)
+
+// parseImportDuration reads a timeout, adding unit to bare numbers
+func parseImportDuration(value, unit string) (Duration, error) {
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		value += unit
+	}
+
+	var d Duration
+	if err := d.UnmarshalText([]byte(value)); err != nil {
+		return 0, err
+	}
+	return d, nil
+}
+
+func parseImportBool(value string) bool {
+	switch strings.ToLower(value) {
+	case "1", "yes", "true", "on":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+//go:embed default_config.toml
+var defaultConfigTemplate []byte
+
+// configPath overrides the default config location when set
+var configPath string
+
+// SetConfigPath makes LoadConfig read path instead of the default location,
+// an empty path restores the default
+func SetConfigPath(path string) {
+	configPath = path
+}
+
+// GetConfigPath returns the config.toml in use, which is the path given to
+// SetConfigPath or $XDG_CONFIG_HOME/end/config.toml
+func GetConfigPath() (string, error) {
+	if configPath != "" {
+		return configPath, nil
+	}
+
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get config directory: %w", err)
+	}
+	return filepath.Join(configDir, "end", "config.toml"), nil
+}
+
+// WriteDefaultConfig writes the commented default config to path, an
+// existing file is only replaced when force is set
+func WriteDefaultConfig(path string, force bool) error {
+	return writeConfigFile(path, defaultConfigTemplate, force)
+}
+
+// WriteImportedConfig writes a config generated by Import to path, an
+// existing file is only replaced when force is set
+func WriteImportedConfig(path string, imported *Imported, from string, force bool) error {
+	var b bytes.Buffer
+	if err := imported.WriteTOML(&b, from); err != nil {
+		return err
+	}
+	return writeConfigFile(path, b.Bytes(), force)
+}
+
+func writeConfigFile(path string, data []byte, force bool) error {
+	if !force {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists, use --force to overwrite it", path)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Urgency names a notification urgency level the way timeouts do
+type Urgency string
+
+const (
+	UrgencyLow      Urgency = "low"
+	UrgencyNormal   Urgency = "normal"
+	UrgencyCritical Urgency = "critical"
+)
+
+func (u *Urgency) UnmarshalText(text []byte) error {
+	switch urgency := Urgency(text); urgency {
+	case UrgencyLow, UrgencyNormal, UrgencyCritical:
+		*u = urgency
+	default:
+		return fmt.Errorf("unknown urgency %q", string(text))
+	}
+	return nil
+}
+
+// Pattern is a regular expression compiled while the config is parsed
+type Pattern struct {
+	*regexp.Regexp
+}
+
+func (p *Pattern) UnmarshalText(text []byte) error {
+	re, err := regexp.Compile(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", string(text), err)
+	}
+	p.Regexp = re
+	return nil
+}
+
+func (p Pattern) MarshalText() ([]byte, error) {
+	if p.Regexp == nil {
+		return nil, nil
+	}
+	return []byte(p.String()), nil
+}
+
+// Rule changes how matching notifications are handled. All set matchers
+// must match; every matching rule applies, later rules overriding earlier
+// ones.
+type Rule struct {
+	// Matchers
+	AppName  string            `toml:"app-name"`
+	Summary  *Pattern          `toml:"summary"`
+	Body     *Pattern          `toml:"body"`
+	Urgency  Urgency           `toml:"urgency"`
+	Category string            `toml:"category"`
+	Hints    map[string]string `toml:"hints"`
+
+	// Actions
+	Timeout     *Duration `toml:"timeout"`
+	Widget      *string   `toml:"widget"`
+	SetUrgency  Urgency   `toml:"set-urgency"`
+	SkipDisplay bool      `toml:"skip-display"`
+	HistoryOnly bool      `toml:"history-only"`
+	Script      string    `toml:"script"`
+}
+
+// RuleSubject is what rules match against
+type RuleSubject struct {
+	AppName  string
+	Summary  string
+	Body     string
+	Urgency  Urgency
+	Category string
+	Hints    map[string]any
+}
+
+// Matches reports whether every matcher set on the rule accepts subject
+func (r Rule) Matches(subject RuleSubject) bool {
+	if r.AppName != "" && r.AppName != subject.AppName {
+		return false
+	}
+	if r.Summary != nil && !r.Summary.MatchString(subject.Summary) {
+		return false
+	}
+	if r.Body != nil && !r.Body.MatchString(subject.Body) {
+		return false
+	}
+	if r.Urgency != "" && r.Urgency != subject.Urgency {
+		return false
+	}
+	if r.Category != "" && r.Category != subject.Category {
+		return false
+	}
+	for key, want := range r.Hints {
+		value, exists := subject.Hints[key]
+		if !exists || fmt.Sprint(value) != want {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// runtimeSetting reads and writes one config value that may be changed
+// while the daemon is running
+type runtimeSetting struct {
+	get func(cfg *Config) string
+	set func(cfg *Config, value string) error
+}
+
+// RuntimeKeys lists the settings accepted by SetValue, in display order
+var RuntimeKeys = []string{
+	"timeout.low",
+	"timeout.normal",
+	"timeout.critical",
+	"max-notifications",
+	"max-notifications-per-app",
+	"notification-orientation",
+	"display-mode",
+	"order",
+	"compact-after",
+	"sound",
+}
+
+var runtimeSettings = map[string]runtimeSetting{
+	"timeout.low":               durationSetting(func(cfg *Config) *Duration { return &cfg.Timeout.ByUrgency.Low }),
+	"timeout.normal":            durationSetting(func(cfg *Config) *Duration { return &cfg.Timeout.ByUrgency.Normal }),
+	"timeout.critical":          durationSetting(func(cfg *Config) *Duration { return &cfg.Timeout.ByUrgency.Critical }),
+	"max-notifications":         uint32Setting(func(cfg *Config) *uint32 { return &cfg.MaxNotifications }),
+	"max-notifications-per-app": uint32Setting(func(cfg *Config) *uint32 { return &cfg.MaxNotificationsPerApp }),
+	"compact-after":             uint32Setting(func(cfg *Config) *uint32 { return &cfg.CompactAfter }),
+	"sound":                     boolSetting(func(cfg *Config) *bool { return &cfg.Sound.Enabled }),
+	"notification-orientation": {
+		get: func(cfg *Config) string { return string(cfg.NotificationOrientation) },
+		set: func(cfg *Config, value string) error {
+			if value != string(Horizontal) && value != string(Vertical) {
+				return fmt.Errorf("orientation must be %q or %q", Horizontal, Vertical)
+			}
+			cfg.NotificationOrientation = Orientation(value)
+			return nil
+		},
+	},
+	"display-mode": {
+		get: func(cfg *Config) string { return string(cfg.DisplayMode) },
+		set: func(cfg *Config, value string) error {
+			return cfg.DisplayMode.UnmarshalText([]byte(value))
+		},
+	},
+	"order": {
+		get: func(cfg *Config) string { return string(cfg.Order) },
+		set: func(cfg *Config, value string) error {
+			return cfg.Order.UnmarshalText([]byte(value))
+		},
+	},
+}
+
+func durationSetting(field func(cfg *Config) *Duration) runtimeSetting {
+	return runtimeSetting{
+		get: func(cfg *Config) string {
+			return field(cfg).String()
+		},
+		set: func(cfg *Config, value string) error {
+			return field(cfg).UnmarshalText([]byte(value))
+		},
+	}
+}
+
+func boolSetting(field func(cfg *Config) *bool) runtimeSetting {
+	return runtimeSetting{
+		get: func(cfg *Config) string {
+			return strconv.FormatBool(*field(cfg))
+		},
+		set: func(cfg *Config, value string) error {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("expected true or false: %w", err)
+			}
+			*field(cfg) = enabled
+			return nil
+		},
+	}
+}
+
+func uint32Setting(field func(cfg *Config) *uint32) runtimeSetting {
+	return runtimeSetting{
+		get: func(cfg *Config) string {
+			return strconv.FormatUint(uint64(*field(cfg)), 10)
+		},
+		set: func(cfg *Config, value string) error {
+			n, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return fmt.Errorf("expected a non-negative integer: %w", err)
+			}
+			*field(cfg) = uint32(n)
+			return nil
+		},
+	}
+}
+
+// SetValue changes a runtime setting by key
+func (c *Config) SetValue(key, value string) error {
+	setting, ok := runtimeSettings[key]
+	if !ok {
+		return fmt.Errorf("unknown or read-only setting %q", key)
+	}
+	if err := setting.set(c, value); err != nil {
+		return fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+	return nil
+}
+
+// GetValue returns the current value of a runtime setting
+func (c *Config) GetValue(key string) (string, error) {
+	setting, ok := runtimeSettings[key]
+	if !ok {
+		return "", fmt.Errorf("unknown setting %q", key)
+	}
+	return setting.get(c), nil
+}
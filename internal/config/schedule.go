@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// TimeRange is a daily window written as "HH:MM-HH:MM", a window ending
+// before it starts runs past midnight
+type TimeRange struct {
+	Start int // Minutes after midnight
+	End   int
+	set   bool
+}
+
+func (r *TimeRange) UnmarshalText(text []byte) error {
+	startText, endText, ok := strings.Cut(string(text), "-")
+	if !ok {
+		return fmt.Errorf("time range %q must look like 22:00-08:00", string(text))
+	}
+
+	start, err := parseClock(startText)
+	if err != nil {
+		return err
+	}
+	end, err := parseClock(endText)
+	if err != nil {
+		return err
+	}
+
+	*r = TimeRange{Start: start, End: end, set: true}
+	return nil
+}
+
+func (r TimeRange) MarshalText() ([]byte, error) {
+	if !r.set {
+		return nil, nil
+	}
+	return []byte(fmt.Sprintf("%02d:%02d-%02d:%02d", r.Start/60, r.Start%60, r.End/60, r.End%60)), nil
+}
+
+// parseClock turns "HH:MM" into minutes after midnight
+func parseClock(text string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(text))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", text)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Weekday is a day name such as "mon" or "monday"
+type Weekday string
+
+func (w *Weekday) UnmarshalText(text []byte) error {
+	if _, err := parseWeekday(string(text)); err != nil {
+		return err
+	}
+	*w = Weekday(strings.ToLower(string(text)))
+	return nil
+}
+
+func parseWeekday(name string) (time.Weekday, error) {
+	name = strings.ToLower(name)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		full := strings.ToLower(day.String())
+		if name == full || name == full[:3] {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday %q", name)
+}
+
+// DndSchedule turns Do-Not-Disturb on during quiet hours
+type DndSchedule struct {
+	// Hours is the daily window, unset disables the schedule
+	Hours TimeRange `toml:"hours"`
+	// Days the window starts on, empty means every day
+	Days []Weekday `toml:"days"`
+	// AllowUrgency lists urgencies still shown during quiet hours
+	AllowUrgency []Urgency `toml:"allow-urgency"`
+}
+
+// Active reports whether now falls into the quiet hours
+func (s DndSchedule) Active(now time.Time) bool {
+	if !s.Hours.set {
+		return false
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	startDay := now.Weekday()
+
+	switch {
+	case s.Hours.Start <= s.Hours.End:
+		if minute < s.Hours.Start || minute >= s.Hours.End {
+			return false
+		}
+	case minute >= s.Hours.Start:
+		// Evening part of an overnight window
+	case minute < s.Hours.End:
+		// Morning part, the window started the day before
+		startDay = (startDay + 6) % 7
+	default:
+		return false
+	}
+
+	if len(s.Days) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(s.Days, func(day Weekday) bool {
+		weekday, err := parseWeekday(string(day))
+		return err == nil && weekday == startDay
+	})
+}
+
+// Allows reports whether notifications of the urgency pass quiet hours
+func (s DndSchedule) Allows(urgency string) bool {
+	return slices.Contains(s.AllowUrgency, Urgency(urgency))
+}
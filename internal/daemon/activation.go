@@ -0,0 +1,33 @@
+package daemon
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// activationTimeout bounds how long the activation command may take, the
+// action waits for it
+const activationTimeout = 2 * time.Second
+
+// activationToken runs the activation command for an xdg-activation token,
+// empty when not running on Wayland or no command is configured
+func (d *Daemon) activationToken() string {
+	command := d.cfg().Actions.ActivationCommand
+	if len(command) == 0 || os.Getenv("WAYLAND_DISPLAY") == "" {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(d.ctx, activationTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, command[0], command[1:]...).Output()
+	if err != nil {
+		slog.Warn("Failed to get an activation token", "err", err)
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
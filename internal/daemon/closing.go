@@ -0,0 +1,130 @@
+package daemon
+
+import (
+	"cmp"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cheezecakee/eww-notify-go/internal/state"
+)
+
+// closingTracker keeps closed notifications on screen in the "closing"
+// state for animation.closing-duration, so eww can play an exit animation
+// instead of the widget vanishing. The state has already let go of them.
+type closingTracker struct {
+	mu       sync.Mutex
+	duration time.Duration
+	closing  map[uint32]closingEntry
+	// refresh redraws the display once the next closing phase is over
+	refresh *time.Timer
+}
+
+type closingEntry struct {
+	notification state.Notification
+	until        time.Time
+}
+
+// setDuration applies animation.closing-duration, in milliseconds
+func (c *closingTracker) setDuration(ms uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.duration = time.Duration(ms) * time.Millisecond
+}
+
+// add starts the closing phase of a notification, unless the phase is off
+func (c *closingTracker) add(notification state.Notification) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.duration <= 0 {
+		return
+	}
+	if c.closing == nil {
+		c.closing = make(map[uint32]closingEntry)
+	}
+
+	notification.Closing = true
+	c.closing[notification.Id] = closingEntry{notification: notification, until: time.Now().Add(c.duration)}
+}
+
+// active returns the notifications still closing, oldest first, and forgets
+// the ones whose phase is over. While any remain, redraw is called once the
+// soonest phase ends.
+func (c *closingTracker) active(now time.Time, redraw func()) []state.Notification {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var notifications []state.Notification
+	var next time.Time
+	for id, entry := range c.closing {
+		if !now.Before(entry.until) {
+			delete(c.closing, id)
+			continue
+		}
+		notifications = append(notifications, entry.notification)
+		if next.IsZero() || entry.until.Before(next) {
+			next = entry.until
+		}
+	}
+
+	if len(notifications) > 0 {
+		if c.refresh == nil {
+			c.refresh = time.AfterFunc(next.Sub(now), redraw)
+		} else {
+			c.refresh.Reset(next.Sub(now))
+		}
+	}
+
+	slices.SortFunc(notifications, func(a, b state.Notification) int { return cmp.Compare(a.Id, b.Id) })
+	return notifications
+}
+
+// startClosing shows a closed notification in the closing state until its
+// phase is over. It runs with the state locked, the display update that
+// follows every close arms the redraw that drops it.
+func (d *Daemon) startClosing(notification state.Notification) {
+	d.closing.add(notification)
+}
+
+// redrawAfterClosing takes notifications whose closing phase is over off
+// the display
+func (d *Daemon) redrawAfterClosing() {
+	if err := d.updateDisplay(); err != nil {
+		slog.Error("Failed to update display after closing", "err", err)
+	}
+}
+
+// withClosing adds the closing notifications to the live ones, each before
+// the first live notification that arrived after it so it stays in place
+// while it animates. A notification that came back, say from history, is
+// shown live only.
+func (d *Daemon) withClosing(live []state.Notification) []state.Notification {
+	closing := d.closing.active(time.Now(), d.redrawAfterClosing)
+	if len(closing) == 0 {
+		return live
+	}
+
+	isLive := make(map[uint32]bool, len(live))
+	for _, notification := range live {
+		isLive[notification.Id] = true
+	}
+
+	merged := make([]state.Notification, 0, len(live)+len(closing))
+	next := 0
+	addClosingBefore := func(id uint32) {
+		for ; next < len(closing) && closing[next].Id < id; next++ {
+			if !isLive[closing[next].Id] {
+				merged = append(merged, closing[next])
+			}
+		}
+	}
+	for _, notification := range live {
+		addClosingBefore(notification.Id)
+		merged = append(merged, notification)
+	}
+	addClosingBefore(^uint32(0))
+	return merged
+}
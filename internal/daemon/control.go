@@ -0,0 +1,178 @@
+package daemon
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+
+	"github.com/cheezecakee/eww-notify-go/internal/state"
+)
+
+const (
+	ControlObjectPath = "/com/github/end/Control"
+	ControlInterface  = "com.github.end.Control"
+)
+
+// ControlServer exposes the daemon controls otherwise available over IPC as
+// D-Bus methods, so they keep working with the IPC server disabled
+type ControlServer struct {
+	daemon *Daemon
+}
+
+// exportControl registers the control interface on the daemon's connection
+func (ns *NotificationServer) exportControl() error {
+	control := &ControlServer{daemon: ns.daemon}
+
+	if err := ns.conn.Export(control, ControlObjectPath, ControlInterface); err != nil {
+		return fmt.Errorf("failed to export control interface: %w", err)
+	}
+
+	err := ns.conn.Export(introspect.Introspectable(control.introspectData()), ControlObjectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		return fmt.Errorf("failed to export control introspection: %w", err)
+	}
+
+	return nil
+}
+
+func (cs *ControlServer) List() (_ string, dbusErr *dbus.Error) {
+	defer recoverCall("Control.List", &dbusErr)
+	slog.Debug("Control.List called")
+	list, err := cs.daemon.ListJSON()
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return list, nil
+}
+
+func (cs *ControlServer) Close(id uint32) (dbusErr *dbus.Error) {
+	defer recoverCall("Control.Close", &dbusErr)
+	slog.Debug("Control.Close called", "id", id)
+	if err := cs.daemon.RemoveNotification(id); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return cs.daemon.dbusServer.HandleDBusError(cs.daemon.dbusServer.EmitNotificationClosed(id, state.Dismiss))
+}
+
+func (cs *ControlServer) CloseAll() (dbusErr *dbus.Error) {
+	defer recoverCall("Control.CloseAll", &dbusErr)
+	slog.Debug("Control.CloseAll called")
+	return cs.daemon.dbusServer.HandleDBusError(cs.daemon.CloseAll())
+}
+
+func (cs *ControlServer) CloseFrom(who string) (_ uint32, dbusErr *dbus.Error) {
+	defer recoverCall("Control.CloseFrom", &dbusErr)
+	slog.Debug("Control.CloseFrom called", "from", who)
+	closed, err := cs.daemon.CloseFrom(who)
+	if err != nil {
+		return 0, dbus.MakeFailedError(err)
+	}
+	return uint32(closed), nil
+}
+
+func (cs *ControlServer) InvokeAction(id uint32, actionKey string) (dbusErr *dbus.Error) {
+	defer recoverCall("Control.InvokeAction", &dbusErr)
+	slog.Debug("Control.InvokeAction called", "id", id, "action", actionKey)
+	return cs.daemon.dbusServer.HandleDBusError(cs.daemon.InvokeAction(id, actionKey))
+}
+
+func (cs *ControlServer) SetDnd(enabled bool) (dbusErr *dbus.Error) {
+	defer recoverCall("Control.SetDnd", &dbusErr)
+	slog.Debug("Control.SetDnd called", "enabled", enabled)
+	return cs.daemon.dbusServer.HandleDBusError(cs.daemon.SetDnd(enabled))
+}
+
+func (cs *ControlServer) GetDnd() (_ bool, dbusErr *dbus.Error) {
+	defer recoverCall("Control.GetDnd", &dbusErr)
+	slog.Debug("Control.GetDnd called")
+	return cs.daemon.state.IsDnd(), nil
+}
+
+func (cs *ControlServer) Pause() (dbusErr *dbus.Error) {
+	defer recoverCall("Control.Pause", &dbusErr)
+	slog.Debug("Control.Pause called")
+	return cs.daemon.dbusServer.HandleDBusError(cs.daemon.PauseTimeouts())
+}
+
+func (cs *ControlServer) Resume() (dbusErr *dbus.Error) {
+	defer recoverCall("Control.Resume", &dbusErr)
+	slog.Debug("Control.Resume called")
+	return cs.daemon.dbusServer.HandleDBusError(cs.daemon.ResumeTimeouts())
+}
+
+func (cs *ControlServer) Status() (_ string, dbusErr *dbus.Error) {
+	defer recoverCall("Control.Status", &dbusErr)
+	slog.Debug("Control.Status called")
+	return cs.daemon.Status(), nil
+}
+
+func (cs *ControlServer) Get(id uint32) (_ string, dbusErr *dbus.Error) {
+	defer recoverCall("Control.Get", &dbusErr)
+	slog.Debug("Control.Get called", "id", id)
+	notification, err := cs.daemon.NotificationJSON(id)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return notification, nil
+}
+
+func (cs *ControlServer) History() (_ string, dbusErr *dbus.Error) {
+	defer recoverCall("Control.History", &dbusErr)
+	slog.Debug("Control.History called")
+	history, err := cs.daemon.HistoryJSON()
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return history, nil
+}
+
+func (cs *ControlServer) Reload() (dbusErr *dbus.Error) {
+	defer recoverCall("Control.Reload", &dbusErr)
+	slog.Debug("Control.Reload called")
+	return cs.daemon.dbusServer.HandleDBusError(cs.daemon.Reload())
+}
+
+func (cs *ControlServer) introspectData() string {
+	return `<interface name="` + ControlInterface + `">
+		<method name="List">
+			<arg direction="out" name="notifications" type="s"/>
+		</method>
+		<method name="Get">
+			<arg direction="in" name="id" type="u"/>
+			<arg direction="out" name="notification" type="s"/>
+		</method>
+		<method name="History">
+			<arg direction="out" name="history" type="s"/>
+		</method>
+		<method name="Close">
+			<arg direction="in" name="id" type="u"/>
+		</method>
+		<method name="CloseAll">
+		</method>
+		<method name="CloseFrom">
+			<arg direction="in" name="app_or_sender" type="s"/>
+			<arg direction="out" name="closed" type="u"/>
+		</method>
+		<method name="InvokeAction">
+			<arg direction="in" name="id" type="u"/>
+			<arg direction="in" name="action_key" type="s"/>
+		</method>
+		<method name="SetDnd">
+			<arg direction="in" name="enabled" type="b"/>
+		</method>
+		<method name="GetDnd">
+			<arg direction="out" name="enabled" type="b"/>
+		</method>
+		<method name="Pause">
+		</method>
+		<method name="Resume">
+		</method>
+		<method name="Status">
+			<arg direction="out" name="status" type="s"/>
+		</method>
+		<method name="Reload">
+		</method>
+	</interface>`
+}
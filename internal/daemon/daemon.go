@@ -1,29 +1,62 @@
 package daemon
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
-	"os/exec"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cheezecakee/eww-notify-go/internal/config"
+	"github.com/cheezecakee/eww-notify-go/internal/history"
 	"github.com/cheezecakee/eww-notify-go/internal/state"
 	"github.com/cheezecakee/eww-notify-go/internal/util/dbus"
+	"github.com/cheezecakee/eww-notify-go/internal/util/xdg"
 )
 
+// Keys of the synthetic actions handled by the daemon instead of being
+// forwarded to the application
+const (
+	DismissActionKey = "__dismiss"
+	ExtendActionKey  = "__extend"
+	// OpenURLActionKey is followed by the index of the link to open
+	OpenURLActionKey = "__open-url:"
+)
+
+// DefaultActionKey is the action the spec invokes when the notification
+// itself is clicked
+const DefaultActionKey = "default"
+
 type Daemon struct {
-	config       config.Config
-	state        *state.NotificationState
-	dbusServer   *NotificationServer
-	ctx          context.Context
-	cancel       context.CancelFunc
-	timeoutTasks map[uint32]context.CancelFunc
+	state         *state.NotificationState
+	dbusServer    *NotificationServer
+	portal        *PortalServer
+	historyStore  *history.Store
+	historyQueue  historyQueue
+	ctx           context.Context
+	cancel        context.CancelFunc
+	timers        timerManager
+	watchdog      ewwWatchdog
+	storm         stormGuard
+	suppressed    suppressionTracker
+	events        eventBus
+	hooks         hookRunner
+	closing       closingTracker
+	metrics       *metrics
+	metricsServer *http.Server
+	done          chan struct{}
+	doneOnce      sync.Once
 }
 
 func NewDaemon(cfg config.Config) (*Daemon, error) {
+	applyLogLevel(cfg)
 	notificationState := state.NewNotificationState(cfg, nil)
 
 	dbusServer, err := NewNotificationServer(notificationState)
@@ -34,46 +67,116 @@ func NewDaemon(cfg config.Config) (*Daemon, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	daemon := &Daemon{
-		config:       cfg,
-		state:        notificationState,
-		dbusServer:   dbusServer,
-		ctx:          ctx,
-		cancel:       cancel,
-		timeoutTasks: make(map[uint32]context.CancelFunc),
+		state:      notificationState,
+		dbusServer: dbusServer,
+		ctx:        ctx,
+		cancel:     cancel,
+		metrics:    newMetrics(),
+		done:       make(chan struct{}),
 	}
 
 	dbusServer.daemon = daemon
+	notificationState.OnHistory = daemon.recordClosed
+	daemon.closing.setDuration(cfg.Animation.ClosingDuration)
 
 	return daemon, nil
 }
 
+// cfg returns the effective configuration, which may change at runtime
+func (d *Daemon) cfg() config.Config {
+	return d.state.GetConfig()
+}
+
 func (d *Daemon) Start() error {
+	if err := d.openHistoryStore(); err != nil {
+		slog.Error("Persistent history unavailable", "err", err)
+	}
+
 	if err := d.dbusServer.SetupDBusService(); err != nil {
 		return fmt.Errorf("failed to setup DBus service: %w", err)
 	}
 
-	fmt.Println("Notification daemon started")
-	go d.cleanupLoop()
+	cfg := d.cfg()
+
+	if cfg.EwwAutostart {
+		if err := d.startEww(); err != nil {
+			slog.Error("Failed to start eww", "err", err)
+		}
+		probeInterval := time.Duration(cfg.EwwWatchdog.ProbeInterval) * time.Second
+		go supervise(d.ctx, "eww supervisor", func() { d.superviseEww(probeInterval) })
+	}
+
+	go supervise(d.ctx, "timers", func() { d.timers.run(d.ctx, d.fireTimer) })
+	d.startMetricsServer()
+
+	if err := d.restoreState(); err != nil {
+		slog.Error("Failed to restore notifications", "err", err)
+	}
+
+	slog.Info("Notification daemon started")
+	go supervise(d.ctx, "cleanup loop", d.cleanupLoop)
+	go supervise(d.ctx, "quiet hours loop", d.dndScheduleLoop)
+	if cfg.SuppressedSummary.Interval > 0 {
+		interval := time.Duration(cfg.SuppressedSummary.Interval) * time.Second
+		go supervise(d.ctx, "suppressed summary loop", func() { d.suppressedSummaryLoop(interval) })
+	}
+	if cfg.ProgressTick > 0 {
+		tick := time.Duration(cfg.ProgressTick) * time.Millisecond
+		go supervise(d.ctx, "progress loop", func() { d.progressLoop(tick) })
+	}
 	return nil
 }
 
+// Done is closed when the daemon wants the process to exit on its own, for
+// example after losing the bus name to another daemon
+func (d *Daemon) Done() <-chan struct{} {
+	return d.done
+}
+
+// shutdown asks the process to exit, see Done
+func (d *Daemon) shutdown() {
+	d.doneOnce.Do(func() { close(d.done) })
+}
+
+// Stop shuts the daemon down. Clients learn that every notification is
+// gone, even ones saved for the next daemon, and the widgets are cleared
+// so nothing stale stays on screen.
 func (d *Daemon) Stop() error {
-	fmt.Println("Stopping notification daemon...")
+	slog.Info("Stopping notification daemon")
 
-	for _, cancel := range d.timeoutTasks {
-		cancel()
+	persisted := false
+	if err := d.saveState(); err != nil {
+		slog.Error("Failed to save notifications", "err", err)
+	} else {
+		persisted = d.cfg().PersistState
 	}
 
+	d.timers.cancelAll()
 	d.cancel()
 
+	if persisted {
+		// Saved notifications stay in the state, closing them would also
+		// file them in the history the next daemon restores them beside
+		for _, notification := range d.state.GetNotifications() {
+			d.dbusServer.EmitNotificationClosed(notification.Id, state.Undefined)
+		}
+	} else {
+		d.closeAll(state.Undefined)
+	}
+	d.clearDisplay()
+
 	if err := d.dbusServer.Close(); err != nil {
 		return fmt.Errorf("failed to close DBus server: %w", err)
 	}
 
+	d.closeHistoryStore()
+	d.stopMetricsServer()
+
 	return nil
 }
 
 func (d *Daemon) HandleNotification(
+	sender string,
 	appName string,
 	replaceId uint32,
 	appIcon string,
@@ -83,76 +186,291 @@ func (d *Daemon) HandleNotification(
 	hints map[string]any,
 	expireTimeout int32,
 ) (uint32, error) {
-	log.Printf("DEBUG: HandleNotification called - App: %s, Summary: %s, Body: %s", appName, summary, body)
-	log.Printf("DEBUG: Hints: %+v", hints)
+	slog.Debug("HandleNotification called", "app", appName, "summary", summary, "body", body, "hints", hints)
+	received := time.Now()
+
+	// Clients disagree on hint types, the rest of the daemon sees one
+	hints = dbus.NormalizeHints(hints)
 
 	var notificationId uint32
 
+	// Per spec a replaces_id that no longer exists makes a new notification,
+	// reusing it could collide with an ID handed out since
+	if replaceId != 0 && !d.state.IsLive(replaceId) {
+		slog.Debug("Notification to replace is gone, showing a new one", "id", replaceId, "app", appName)
+		replaceId = 0
+	}
+	if replaceId != 0 && !d.canReplace(replaceId, sender, appName) {
+		slog.Warn("App may not replace a notification of another app, showing it as new", "id", replaceId, "app", appName)
+		replaceId = 0
+	}
+
+	// Progress updates (volume, brightness, downloads) replace the app's
+	// progress notification on screen instead of stacking up
+	if _, ok := dbus.GetProgress(hints); ok && replaceId == 0 {
+		if existingId, found := d.state.FindByAppHint(d.cfg().NormalizeAppName(appName), dbus.HintKeyValue); found {
+			replaceId = existingId
+		}
+	}
+
+	// Notifications sharing a stack tag replace each other whatever their
+	// IDs, volume and brightness scripts rarely keep track of those
+	stackTag, _ := dbus.GetStackTag(hints)
+	if stackTag != "" && replaceId == 0 {
+		if existingId, found := d.state.FindByStackTag(d.cfg().NormalizeAppName(appName), stackTag); found {
+			replaceId = existingId
+		}
+	}
+
 	if replaceId != 0 {
 		notificationId = replaceId
 	} else {
 		notificationId = d.state.NextId()
 	}
 
+	cfg := d.cfg()
+
+	// Everything past this point sees the canonical app name
+	appName = cfg.NormalizeAppName(appName)
+
+	// Widgets expect plain paths, not file:// URIs
+	appIcon = fileURIPath(appIcon)
+
+	// The desktop entry names the app properly when app_name is cryptic
+	var displayName string
+	if desktopId, ok := dbus.GetStringHint(hints, dbus.HintKeyDesktopEntry); ok {
+		if entry, found := xdg.LookupDesktopEntry(desktopId); found {
+			displayName = entry.Name
+			if appIcon == "" {
+				appIcon = entry.Icon
+			}
+		}
+	}
+
+	// Widgets can't show themed icon names, only files
+	if path, ok := d.lookupIcon(appIcon); ok {
+		appIcon = path
+	}
+
 	// Determine timeout from hints and config
 	urgency := dbus.GetUrgency(hints)
 	urgencyKey := dbus.ConfigKeyUrgency(urgency)
 
-	var timeout uint32
+	category, _ := dbus.GetStringHint(hints, dbus.HintKeyCategory)
+	rules := d.matchRules(config.RuleSubject{
+		AppName:  appName,
+		Summary:  summary,
+		Body:     body,
+		Urgency:  config.Urgency(urgencyKey),
+		Category: category,
+		Hints:    hints,
+	})
+	if rules.urgency != "" {
+		if hints == nil {
+			hints = make(map[string]any)
+		}
+		hints[dbus.HintKeyUrgency] = urgencyHint(rules.urgency)
+		urgencyKey = string(rules.urgency)
+	}
+	d.metrics.countReceived(appName, urgencyKey)
+
+	var timeout time.Duration
+	var clientMode config.ClientTimeout
 	switch urgencyKey {
 	case "low":
-		timeout = d.config.Timeout.ByUrgency.Low
+		timeout = cfg.Timeout.ByUrgency.Low.Std()
+		clientMode = cfg.Timeout.Client.Low
 	case "critical":
-		timeout = d.config.Timeout.ByUrgency.Critical
+		timeout = cfg.Timeout.ByUrgency.Critical.Std()
+		clientMode = cfg.Timeout.Client.Critical
 	default: // "normal"
-		timeout = d.config.Timeout.ByUrgency.Normal
+		timeout = cfg.Timeout.ByUrgency.Normal.Std()
+		clientMode = cfg.Timeout.Client.Normal
 	}
+	timeout = clientTimeout(timeout, expireTimeout, clientMode)
 
-	// Force timeout for battery notifications if they're set to 0 (persistent)
-	if notifyType, exists := hints["type"]; exists {
-		if typeStr, ok := notifyType.(string); ok && typeStr == "battery" && timeout == 0 {
-			timeout = 10 // 10 seconds default for battery notifications
-		}
+	categoryCfg, hasCategory := cfg.Category(category)
+	if hasCategory && categoryCfg.Timeout != nil {
+		timeout = categoryCfg.Timeout.Std()
+	}
+
+	notifyType, typeCfg, hasType := d.notificationType(hints)
+	if hasType && typeCfg.Timeout != nil {
+		timeout = typeCfg.Timeout.Std()
+	}
+
+	if rules.timeout != nil {
+		timeout = rules.timeout.Std()
+	}
+
+	// In compact mode notifications shrink instead of expiring
+	compactAfter := cfg.CompactAfter
+	if compactAfter > 0 {
+		timeout = 0
 	}
 
 	// Create notification
 	notification := state.Notification{
-		Id:         notificationId,
-		Timeout:    timeout,
-		Timestamp:  time.Now(),
-		NotifyType: nil,
-		AppName:    appName,
-		AppIcon:    appIcon,
-		Summary:    summary,
-		Body:       body,
-		Hints:      hints,
-		Actions:    actions,
-		Widget:     d.config.EwwDefaultNotificationKey,
+		Id:          notificationId,
+		Timeout:     timeout,
+		Timestamp:   time.Now(),
+		Created:     received,
+		NotifyType:  notifyType,
+		AppName:     appName,
+		AppIcon:     appIcon,
+		DisplayName: displayName,
+		Summary:     summary,
+		Body:        formatBody(body, cfg.BodyMarkup),
+		Links:       extractLinks(body),
+		Hints:       hints,
+		Actions:     actions,
+		Widget:      cfg.EwwDefaultNotificationKey,
+		ExtraClass:  d.extraClassFromHints(hints),
+		Image:       d.resolveImage(hints, appIcon),
+		Sender:      sender,
+		Count:       1,
+		StackTag:    stackTag,
+	}
+
+	if action, filtered := cfg.AppFilter.Check(appName); filtered {
+		if action == config.FilterHistory {
+			slog.Debug("Sending notification from filtered app to history", "id", notificationId, "app", appName)
+			d.state.AddHistory(notification, state.Undefined)
+		} else {
+			slog.Debug("Dropping notification from filtered app", "id", notificationId, "app", appName)
+		}
+		d.recordSuppressed(appName)
+		d.closeUnshown(notificationId)
+		return notificationId, nil
+	}
+
+	if hasCategory && categoryCfg.Widget != "" {
+		notification.Widget = &categoryCfg.Widget
+	}
+	if hasType && typeCfg.Widget != "" {
+		notification.Widget = &typeCfg.Widget
+	}
+	if rules.widget != nil {
+		notification.Widget = rules.widget
+	}
+
+	for _, script := range rules.scripts {
+		runRuleScript(script, notification)
+	}
+
+	if rules.skipDisplay {
+		slog.Debug("Dropping notification, a rule skips its display", "id", notificationId, "app", appName)
+		d.recordSuppressed(appName)
+		d.closeUnshown(notificationId)
+		return notificationId, nil
+	}
+
+	if rules.historyOnly {
+		slog.Debug("Sending notification straight to history", "id", notificationId, "app", appName)
+		d.state.AddHistory(notification, state.Undefined)
+		d.recordSuppressed(appName)
+		d.closeUnshown(notificationId)
+		return notificationId, nil
+	}
+
+	if cfg.WorkspaceRouting != config.RoutingOff {
+		monitor, err := d.resolveMonitor(sender)
+		if err != nil {
+			slog.Warn("Could not route notification to a monitor", "id", notificationId, "app", appName, "err", err)
+		}
+		notification.Monitor = monitor
+	}
+
+	if d.state.IsMuted(appName) {
+		slog.Debug("Suppressing notification from muted app", "id", notificationId, "app", appName)
+		d.state.AddHistory(notification, state.Undefined)
+		d.recordSuppressed(appName)
+		d.closeUnshown(notificationId)
+		return notificationId, nil
+	}
+
+	if d.shouldQueueForDnd(urgencyKey) {
+		slog.Debug("Queueing notification while Do-Not-Disturb is on", "id", notificationId, "app", appName)
+		d.state.QueueForDnd(notification)
+		return notificationId, nil
+	}
+
+	if window := cfg.DuplicateWindow.Std(); window > 0 && replaceId == 0 {
+		if existing, ok := d.state.Coalesce(notification, window); ok {
+			return existing.Id, d.coalesced(existing)
+		}
 	}
 
 	d.state.AddNotification(notification)
+	d.events.publish(Event{Event: "notify", Id: notificationId, AppName: appName, Summary: summary})
+	d.runHook("notify", notification)
+	sound := categoryCfg.Sound
+	if hasType && typeCfg.Sound != "" {
+		sound = typeCfg.Sound
+	}
+	d.playSound(hints, urgencyKey, sound, replaceId != 0)
+
+	// Replace storms only update state, the display catches up once per window
+	if replaceId != 0 && d.throttleReplace(appName) {
+		if timeout > 0 && !d.state.IsPaused() {
+			d.scheduleTimeout(notificationId, timeout)
+		}
+		return notificationId, nil
+	}
 
-	if timeout > 0 {
-		log.Printf("DEBUG: Scheduling timeout for notification %d: %d seconds", notificationId, timeout)
-		d.scheduleTimeout(notificationId, time.Duration(timeout)*time.Second)
+	if d.state.IsPaused() {
+		slog.Debug("Timeouts are paused, notification is armed on resume", "id", notificationId)
+	} else if timeout > 0 {
+		slog.Debug("Scheduling timeout", "id", notificationId, "timeout", timeout)
+		d.scheduleTimeout(notificationId, timeout)
+	} else if compactAfter > 0 {
+		slog.Debug("Scheduling compaction", "id", notificationId, "seconds", compactAfter)
+		d.scheduleCompact(notificationId, time.Duration(compactAfter)*time.Second)
 	} else {
-		log.Printf("DEBUG: No timeout set for notification %d (timeout=0)", notificationId)
+		slog.Debug("No timeout set", "id", notificationId)
 	}
 
 	if err := d.updateDisplay(); err != nil {
 		return notificationId, fmt.Errorf("failed to update display: %w", err)
 	}
+	d.metrics.observeLatency(time.Since(received))
 
 	return notificationId, nil
 }
 
+// closeUnshown tells the client that a notification which never reached the
+// screen is closed, so nothing waits on it forever. A dropped replacement
+// leaves the notification it was meant to replace on screen.
+func (d *Daemon) closeUnshown(id uint32) {
+	if d.state.IsLive(id) {
+		return
+	}
+	if err := d.dbusServer.EmitNotificationClosed(id, state.Undefined); err != nil {
+		slog.Warn("Failed to emit NotificationClosed", "id", id, "err", err)
+	}
+}
+
 func (d *Daemon) RemoveNotification(id uint32) error {
-	if cancel, exists := d.timeoutTasks[id]; exists {
-		cancel()
-		delete(d.timeoutTasks, id)
+	return d.closeNotification(id, state.Dismiss)
+}
+
+// closeAll takes every notification off screen, telling clients with
+// NotificationClosed
+func (d *Daemon) closeAll(reason state.NotificationCloseReason) {
+	for _, notification := range d.state.GetNotifications() {
+		d.timers.cancel(notification.Id)
+		if d.state.RemoveNotification(notification.Id, reason) {
+			d.dbusServer.EmitNotificationClosed(notification.Id, reason)
+		}
 	}
+}
+
+// closeNotification takes a notification off screen, recording reason in
+// the history
+func (d *Daemon) closeNotification(id uint32, reason state.NotificationCloseReason) error {
+	d.timers.cancel(id)
 
-	if !d.state.RemoveNotification(id) {
+	if !d.state.RemoveNotification(id, reason) {
 		return fmt.Errorf("notification with ID %d not found", id)
 	}
 
@@ -160,40 +478,666 @@ func (d *Daemon) RemoveNotification(id uint32) error {
 }
 
 func (d *Daemon) InvokeAction(id uint32, actionKey string) error {
-	if _, exists := d.state.GetNotificationsById(id); !exists {
+	notification, exists := d.state.GetNotificationsById(id)
+	if !exists {
 		return fmt.Errorf("notification with ID %d not found", id)
 	}
 
-	return d.dbusServer.EmitActionInvoked(id, actionKey)
+	if index, ok := strings.CutPrefix(actionKey, OpenURLActionKey); ok {
+		if err := d.openLink(notification, index); err != nil {
+			return err
+		}
+		return d.closeAfterAction(id)
+	}
+
+	switch actionKey {
+	case ReplyActionKey:
+		return fmt.Errorf("the %s action takes a reply text, use the reply command", ReplyActionKey)
+	case DismissActionKey:
+		if err := d.RemoveNotification(id); err != nil {
+			return err
+		}
+		return d.dbusServer.EmitNotificationClosed(id, state.Dismiss)
+	case ExtendActionKey:
+		return d.ExtendTimeout(id, time.Duration(d.cfg().Actions.ExtendBy)*time.Second)
+	case HistoryActionKey:
+		if d.cfg().CenterWindow == nil {
+			return fmt.Errorf("no center-window configured")
+		}
+		if err := d.RemoveNotification(id); err != nil {
+			return err
+		}
+		d.dbusServer.EmitNotificationClosed(id, state.Dismiss)
+		return d.SetCenter(true)
+	}
+
+	d.runHook("action", notification, "END_ACTION="+actionKey)
+
+	// The app needs the token before it handles the action
+	if token := d.activationToken(); token != "" {
+		if err := d.dbusServer.EmitActivationToken(id, token); err != nil {
+			slog.Warn("Failed to emit activation token", "id", id, "err", err)
+		}
+	}
+
+	// Portal notifications are answered through the portal
+	routed, err := d.portal.invokeAction(id, actionKey)
+	if err != nil {
+		return err
+	}
+	if !routed {
+		if err := d.dbusServer.EmitActionInvoked(id, actionKey); err != nil {
+			return err
+		}
+	}
+
+	return d.closeAfterAction(id)
+}
+
+// closeAfterAction removes a notification once one of its actions was
+// invoked, unless actions.close-on-action is off or the client marked it
+// resident to keep it on screen
+func (d *Daemon) closeAfterAction(id uint32) error {
+	if !d.cfg().Actions.CloseOnAction {
+		return nil
+	}
+
+	notification, exists := d.state.GetNotificationsById(id)
+	if !exists {
+		return nil
+	}
+	if resident, _ := dbus.GetBoolHint(notification.Hints, dbus.HintKeyResident); resident {
+		return nil
+	}
+
+	if err := d.RemoveNotification(id); err != nil {
+		return err
+	}
+	return d.dbusServer.EmitNotificationClosed(id, state.Dismiss)
+}
+
+// Status returns a short human readable summary of the daemon state
+func (d *Daemon) Status() string {
+	ewwStatus := "ok"
+	if d.IsEwwDegraded() {
+		ewwStatus = "degraded"
+	}
+
+	return fmt.Sprintf("notifications: %d\neww: %s\npaused: %t\nsocket: %s\n",
+		len(d.state.GetNotifications()), ewwStatus, d.state.IsPaused(), socketPath) + d.DndStatus()
+}
+
+// CloseAll dismisses every active notification
+func (d *Daemon) CloseAll() error {
+	for _, notification := range d.state.GetNotifications() {
+		if err := d.RemoveNotification(notification.Id); err != nil {
+			continue
+		}
+		if err := d.dbusServer.EmitNotificationClosed(notification.Id, state.Dismiss); err != nil {
+			slog.Error("Failed to emit NotificationClosed", "id", notification.Id, "err", err)
+		}
+	}
+	return nil
+}
+
+// canReplace reports whether a client may replace notification id. Apps
+// may only replace their own notifications, recognized by the D-Bus
+// sender or, for short lived clients like notify-send, the app name.
+func (d *Daemon) canReplace(id uint32, sender, appName string) bool {
+	existing, ok := d.state.GetNotificationsById(id)
+	if !ok || existing.Sender == "" {
+		return true
+	}
+	return existing.Sender == sender || existing.AppName == d.cfg().NormalizeAppName(appName)
+}
+
+// CloseFrom closes every notification of an app, given by its name or
+// D-Bus sender, and returns how many were closed
+func (d *Daemon) CloseFrom(who string) (int, error) {
+	closed := 0
+	for _, notification := range d.state.GetNotifications() {
+		if notification.Sender != who && notification.AppName != d.cfg().NormalizeAppName(who) {
+			continue
+		}
+		if err := d.RemoveNotification(notification.Id); err != nil {
+			continue
+		}
+		if err := d.dbusServer.EmitNotificationClosed(notification.Id, state.Dismiss); err != nil {
+			slog.Error("Failed to emit NotificationClosed", "id", notification.Id, "err", err)
+		}
+		closed++
+	}
+	if closed == 0 {
+		return 0, fmt.Errorf("no notifications from %s", who)
+	}
+	return closed, nil
+}
+
+// Count returns the number of active notifications matching the filters,
+// empty filters match everything
+func (d *Daemon) Count(urgency, appName string) int {
+	if appName != "" {
+		appName = d.cfg().NormalizeAppName(appName)
+	}
+
+	count := 0
+	for _, notification := range d.state.GetNotifications() {
+		if urgency != "" && dbus.ConfigKeyUrgency(dbus.GetUrgency(notification.Hints)) != urgency {
+			continue
+		}
+		if appName != "" && notification.AppName != appName {
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+// ListJSON returns the active notifications as a JSON array
+func (d *Daemon) ListJSON() (string, error) {
+	notifications := d.state.GetNotifications()
+
+	list := make([]map[string]any, 0, len(notifications))
+	for _, notification := range notifications {
+		list = append(list, d.listEntry(notification))
+	}
+
+	jsonBytes, err := json.Marshal(list)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal notifications: %w", err)
+	}
+	return string(jsonBytes), nil
+}
+
+// NotificationJSON returns everything known about a single notification as
+// a JSON object
+func (d *Daemon) NotificationJSON(id uint32) (string, error) {
+	notification, ok := d.state.GetNotificationsById(id)
+	if !ok {
+		return "", fmt.Errorf("notification with ID %d not found", id)
+	}
+
+	entry := d.listEntry(notification)
+	entry["app_icon"] = notification.AppIcon
+	entry["hints"] = notification.Hints
+	entry["compact"] = notification.Compact
+	entry["paused"] = notification.Paused
+	entry["read"] = notification.Read
+	entry["monitor"] = notification.Monitor
+	entry["image"] = notification.Image
+	if notification.Timeout > 0 {
+		entry["remaining"] = max(0, notification.Timeout.Seconds()*notification.TimeLeftFraction(d.state.GetPausedAt()))
+	}
+
+	jsonBytes, err := json.Marshal(entry)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal notification: %w", err)
+	}
+	return string(jsonBytes), nil
+}
+
+// clientTimeout combines the urgency timeout with the expire_timeout the
+// client asked for, -1 leaves the urgency timeout, 0 never expires and
+// positive values are milliseconds
+func clientTimeout(timeout time.Duration, expireTimeout int32, mode config.ClientTimeout) time.Duration {
+	if expireTimeout < 0 || mode == config.ClientIgnore {
+		return timeout
+	}
+
+	requested := time.Duration(expireTimeout) * time.Millisecond
+	if mode == config.ClientClamp && timeout > 0 && (requested == 0 || requested > timeout) {
+		return timeout
+	}
+	return requested
+}
+
+// listEntry holds the fields shared by the list and get replies
+func (d *Daemon) listEntry(notification state.Notification) map[string]any {
+	return map[string]any{
+		"id":               notification.Id,
+		"app_name":         notification.AppName,
+		"app_display_name": notification.AppDisplayName(),
+		"category":         notificationCategory(notification),
+		"sender":           notification.Sender,
+		"count":            notification.Copies(),
+		"pinned":           notification.Pinned,
+		"summary":          notification.Summary,
+		"body":             notification.Body,
+		"urgency":          dbus.ConfigKeyUrgency(dbus.GetUrgency(notification.Hints)),
+		"actions":          d.buildActionsArray(notification),
+		"timestamp":        notification.Timestamp.Unix(),
+		"timeout":          notification.Timeout.Seconds(),
+	}
+}
+
+// HistoryJSON returns the closed notifications as a JSON array, most
+// recently closed first
+func (d *Daemon) HistoryJSON() (string, error) {
+	history := d.state.GetHistory()
+
+	list := make([]map[string]any, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		entry := history[i]
+		list = append(list, map[string]any{
+			"id":          entry.Notification.Id,
+			"app_name":    entry.Notification.AppName,
+			"summary":     entry.Notification.Summary,
+			"body":        entry.Notification.Body,
+			"urgency":     dbus.ConfigKeyUrgency(dbus.GetUrgency(entry.Notification.Hints)),
+			"timestamp":   entry.Notification.Timestamp.Unix(),
+			"closed_at":   entry.ClosedAt.Unix(),
+			"reason":      entry.Reason.String(),
+			"reason_code": entry.Reason.Code(),
+		})
+	}
+
+	jsonBytes, err := json.Marshal(list)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal history: %w", err)
+	}
+	return string(jsonBytes), nil
+}
+
+// PopHistory brings the most recently closed notification back on screen
+// under a new ID
+func (d *Daemon) PopHistory() error {
+	entry, ok := d.state.PopHistory()
+	if !ok {
+		return fmt.Errorf("history is empty")
+	}
+
+	// The restored copy starts over as if it just arrived
+	notification := entry.Notification
+	notification.Id = d.state.NextId()
+	notification.Timestamp = time.Now()
+	notification.Created = notification.Timestamp
+	notification.Compact = false
+	notification.Paused = false
+	notification.PausedAt = time.Time{}
+	notification.Closing = false
+	d.state.AddNotification(notification)
+
+	// While paused ResumeTimeouts arms it with the rest
+	if notification.Timeout > 0 && !d.state.IsPaused() {
+		d.scheduleTimeout(notification.Id, notification.Timeout)
+	}
+
+	return d.updateDisplay()
+}
+
+// DumpDiagnostics returns the stacks of all goroutines plus a summary of
+// the active notifications, for attaching to bug reports
+func (d *Daemon) DumpDiagnostics() string {
+	var b strings.Builder
+
+	b.WriteString("=== Daemon status ===\n")
+	b.WriteString(d.Status())
+	for _, notification := range d.state.GetNotifications() {
+		fmt.Fprintf(&b, "  id=%d app=%q timeout=%s age=%s\n",
+			notification.Id, notification.AppName, notification.Timeout,
+			time.Since(notification.Timestamp).Round(time.Second))
+	}
+	fmt.Fprintf(&b, "pending timers: %d\n", d.timers.pending())
+
+	buf := make([]byte, 1<<20)
+	n := runtime.Stack(buf, true)
+	fmt.Fprintf(&b, "=== Goroutines (%d) ===\n%s", runtime.NumGoroutine(), buf[:n])
+
+	return b.String()
+}
+
+// Reload re-reads the config file and applies it without dropping the
+// active notifications
+func (d *Daemon) Reload() error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to reload config: %w", err)
+	}
+
+	applyLogLevel(*cfg)
+	d.closing.setDuration(cfg.Animation.ClosingDuration)
+	d.state.UpdateConfig(*cfg)
+	slog.Info("Configuration reloaded")
+	return d.updateDisplay()
+}
+
+// SetConfigValue applies a session-only setting and refreshes the display
+func (d *Daemon) SetConfigValue(key, value string) error {
+	if err := d.state.SetConfigValue(key, value); err != nil {
+		return err
+	}
+	return d.updateDisplay()
+}
+
+// GetConfigValues returns "key = value" lines for the given settings, or all
+// runtime settings when none are given. Session overrides are marked.
+func (d *Daemon) GetConfigValues(keys []string) (string, error) {
+	if len(keys) == 0 {
+		keys = config.RuntimeKeys
+	}
+
+	cfg := d.cfg()
+	var b strings.Builder
+	for _, key := range keys {
+		value, err := cfg.GetValue(key)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s = %s", key, value)
+		if d.state.IsOverridden(key) {
+			b.WriteString(" (session override)")
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+// SetCenter opens or closes the notification center window
+func (d *Daemon) SetCenter(open bool) error {
+	window := d.cfg().CenterWindow
+	if window == nil {
+		return fmt.Errorf("no center-window configured")
+	}
+
+	var err error
+	if open {
+		err = d.openEwwWindow(*window)
+	} else {
+		err = d.closeEwwWindow(*window)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to toggle center window: %w", err)
+	}
+
+	d.state.SetCenterOpen(open)
+	if err := d.setEwwValue("end-center-open", strconv.FormatBool(open)); err != nil {
+		return fmt.Errorf("failed to set eww value: %w", err)
+	}
+	return d.updateDisplay()
+}
+
+// ToggleCenter flips the notification center between open and closed
+func (d *Daemon) ToggleCenter() error {
+	return d.SetCenter(!d.state.IsCenterOpen())
+}
+
+// SelectNext moves the keyboard selection to the next notification
+func (d *Daemon) SelectNext() error {
+	d.state.SelectNext()
+	return d.updateDisplay()
+}
+
+// SelectPrev moves the keyboard selection to the previous notification
+func (d *Daemon) SelectPrev() error {
+	d.state.SelectPrev()
+	return d.updateDisplay()
+}
+
+// ActivateSelected invokes the default action of the selected notification
+func (d *Daemon) ActivateSelected() error {
+	notification, ok := d.state.GetSelected()
+	if !ok {
+		return fmt.Errorf("no notification selected")
+	}
+	return d.invokeDefaultAction(notification)
+}
+
+// ActivateLatest invokes the default action of the newest notification
+func (d *Daemon) ActivateLatest() error {
+	notification, ok := d.state.GetLatest()
+	if !ok {
+		return fmt.Errorf("no active notifications")
+	}
+	return d.invokeDefaultAction(notification)
+}
+
+// Activate invokes the default action of the given notification
+func (d *Daemon) Activate(id uint32) error {
+	notification, ok := d.state.GetNotificationsById(id)
+	if !ok {
+		return fmt.Errorf("notification %d not found", id)
+	}
+	return d.invokeDefaultAction(notification)
+}
+
+// invokeDefaultAction invokes the "default" action, falling back to the
+// notification's first action
+func (d *Daemon) invokeDefaultAction(notification state.Notification) error {
+	actions := notification.Actions
+	if len(actions) < 2 {
+		return fmt.Errorf("notification %d has no actions", notification.Id)
+	}
+
+	actionKey := actions[0]
+	for i := 0; i+1 < len(actions); i += 2 {
+		if actions[i] == DefaultActionKey {
+			actionKey = actions[i]
+			break
+		}
+	}
+
+	return d.InvokeAction(notification.Id, actionKey)
+}
+
+// DismissSelected closes the selected notification
+func (d *Daemon) DismissSelected() error {
+	notification, ok := d.state.GetSelected()
+	if !ok {
+		return fmt.Errorf("no notification selected")
+	}
+
+	if err := d.RemoveNotification(notification.Id); err != nil {
+		return err
+	}
+	return d.dbusServer.EmitNotificationClosed(notification.Id, state.Dismiss)
+}
+
+// CycleStack shows the next older entry of an app's stack
+func (d *Daemon) CycleStack(appName string) error {
+	d.state.CycleStack(appName)
+	return d.updateDisplay()
+}
+
+// SetGroupExpanded expands or collapses an app's group in grouped display
+// mode
+func (d *Daemon) SetGroupExpanded(appName string, expanded bool) error {
+	d.state.SetExpanded(appName, expanded)
+	return d.updateDisplay()
+}
+
+// PauseTimeouts freezes every notification's expiry until ResumeTimeouts
+func (d *Daemon) PauseTimeouts() error {
+	if !d.state.Pause() {
+		return nil
+	}
+
+	d.timers.cancelAll()
+
+	slog.Info("Timeouts paused")
+	d.events.publish(Event{Event: "pause"})
+	return nil
+}
+
+// ResumeTimeouts re-arms every notification with the time it had left
+func (d *Daemon) ResumeTimeouts() error {
+	if !d.state.Resume() {
+		return nil
+	}
+
+	compactAfter := time.Duration(d.cfg().CompactAfter) * time.Second
+	for _, notification := range d.state.GetNotifications() {
+		if notification.Paused {
+			continue
+		}
+		age := time.Since(notification.Timestamp)
+		switch {
+		case notification.Timeout > 0:
+			d.scheduleTimeout(notification.Id, notification.Timeout-age)
+		case compactAfter > 0 && !notification.Compact:
+			d.scheduleCompact(notification.Id, compactAfter-age)
+		}
+	}
+
+	slog.Info("Timeouts resumed")
+	d.events.publish(Event{Event: "resume"})
+	return d.updateDisplay()
+}
+
+// PauseTimeout stops a single notification from expiring, e.g. while the
+// pointer hovers over it
+func (d *Daemon) PauseTimeout(id uint32) error {
+	paused, err := d.state.PauseNotification(id)
+	if err != nil || !paused {
+		return err
+	}
+
+	d.timers.cancel(id)
+
+	slog.Debug("Paused timeout", "id", id)
+	return d.updateDisplay()
+}
+
+// ResumeTimeout re-arms a notification paused with PauseTimeout with the
+// time it had left
+func (d *Daemon) ResumeTimeout(id uint32) error {
+	notification, resumed, err := d.state.ResumeNotification(id)
+	if err != nil || !resumed {
+		return err
+	}
+
+	slog.Debug("Resumed timeout", "id", id)
+	if !d.state.IsPaused() {
+		age := time.Since(notification.Timestamp)
+		compactAfter := time.Duration(d.cfg().CompactAfter) * time.Second
+		switch {
+		case notification.Timeout > 0:
+			d.scheduleTimeout(id, notification.Timeout-age)
+		case compactAfter > 0 && !notification.Compact:
+			d.scheduleCompact(id, compactAfter-age)
+		}
+	}
+	return d.updateDisplay()
+}
+
+// SetPinned pins a notification, keeping it on screen until it is closed,
+// or unpins it with a fresh timeout
+func (d *Daemon) SetPinned(id uint32, pinned bool) error {
+	notification, changed, err := d.state.SetPinned(id, pinned)
+	if err != nil || !changed {
+		return err
+	}
+
+	if pinned {
+		slog.Debug("Pinned notification", "id", id)
+		d.timers.cancel(id)
+	} else {
+		slog.Debug("Unpinned notification", "id", id)
+		if notification.Timeout > 0 && !notification.Paused && !d.state.IsPaused() {
+			d.scheduleTimeout(id, notification.Timeout)
+		}
+	}
+	return d.updateDisplay()
 }
 
+// ExtendTimeout keeps a notification on screen for the extra duration
+func (d *Daemon) ExtendTimeout(id uint32, extra time.Duration) error {
+	remaining, err := d.state.ExtendTimeout(id, extra)
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("Extended notification", "id", id, "by", extra, "left", remaining.Round(time.Second))
+	if notification, ok := d.state.GetNotificationsById(id); ok && !notification.Paused && !d.state.IsPaused() {
+		d.scheduleTimeout(id, remaining)
+	}
+	return d.updateDisplay()
+}
+
+// scheduleTimeout expires a notification once the duration has passed,
+// replacing any timer it had
 func (d *Daemon) scheduleTimeout(id uint32, duration time.Duration) {
-	if cancel, exists := d.timeoutTasks[id]; exists {
-		cancel()
+	// Pinned notifications stay until closed, SetPinned re-arms on unpin
+	if d.state.IsPinned(id) {
+		d.timers.cancel(id)
+		return
 	}
 
-	ctx, cancel := context.WithCancel(d.ctx)
-	d.timeoutTasks[id] = cancel
+	d.timers.schedule(id, timerExpire, duration)
+}
 
-	go func() {
-		select {
-		case <-time.After(duration):
-			d.state.RemoveNotification(id)
-			d.dbusServer.EmitNotificationClosed(id, state.Expired)
-			d.updateDisplay()
-			delete(d.timeoutTasks, id)
-		case <-ctx.Done():
+// coalesced restarts the timeout of a notification that just absorbed a
+// duplicate and shows the new count
+func (d *Daemon) coalesced(notification state.Notification) error {
+	slog.Debug("Coalesced duplicate", "id", notification.Id, "app", notification.AppName, "count", notification.Count)
+
+	compactAfter := time.Duration(d.cfg().CompactAfter) * time.Second
+	switch {
+	case d.state.IsPaused() || notification.Paused:
+	case notification.Timeout > 0:
+		d.scheduleTimeout(notification.Id, notification.Timeout)
+	case compactAfter > 0:
+		d.scheduleCompact(notification.Id, compactAfter)
+	}
+
+	if err := d.updateDisplay(); err != nil {
+		return fmt.Errorf("failed to update display: %w", err)
+	}
+	return nil
+}
+
+// scheduleCompact switches a notification to its compact representation
+// once the duration has passed
+func (d *Daemon) scheduleCompact(id uint32, duration time.Duration) {
+	d.timers.schedule(id, timerCompact, duration)
+}
+
+// fireTimer handles a notification's timer coming due, called from the
+// timer manager's goroutine
+func (d *Daemon) fireTimer(id uint32, kind timerKind) {
+	switch kind {
+	case timerExpire:
+		// The notification may have changed since the timer was taken due
+		if !d.state.ExpireNotification(id) {
 			return
 		}
-	}()
+		d.dbusServer.EmitNotificationClosed(id, state.Expired)
+		d.updateDisplay()
+	case timerCompact:
+		if d.state.SetCompact(id) {
+			d.updateDisplay()
+		}
+	}
+}
+
+// clearDisplay empties the notification widgets and closes the popup
+// window, whatever the state holds
+func (d *Daemon) clearDisplay() {
+	if d.IsEwwDegraded() {
+		return
+	}
+
+	if err := d.setEwwValue("end-notifications", ""); err != nil {
+		slog.Warn("Failed to clear notifications", "err", err)
+	}
+	if window := d.cfg().EwwWindow; window != nil {
+		if err := d.closeEwwWindow(*window); err != nil {
+			slog.Warn("Failed to close window", "window", *window, "err", err)
+		}
+	}
 }
 
 func (d *Daemon) updateDisplay() error {
-	notifications := d.state.GetNotifications()
+	// Notifications keep piling up in state, the watchdog resyncs once eww
+	// is back
+	if d.IsEwwDegraded() {
+		return nil
+	}
+
+	notifications := d.withClosing(d.state.GetNotifications())
 
 	if len(notifications) == 0 {
-		if d.config.EwwWindow != nil {
-			return d.closeEwwWindow(*d.config.EwwWindow)
+		if window := d.cfg().EwwWindow; window != nil {
+			return d.closeEwwWindow(*window)
 		}
 		// Even if no window is configured, we should clear the variable
 		return d.setEwwValue("end-notifications", "")
@@ -201,14 +1145,23 @@ func (d *Daemon) updateDisplay() error {
 
 	// Build widget string
 	widgetString := d.buildWidgetString(notifications)
-	log.Printf("DEBUG: Built widget string: %s", widgetString)
+	slog.Debug("Built widget string", "widgets", widgetString)
 
 	if err := d.setEwwValue("end-notifications", widgetString); err != nil {
 		return fmt.Errorf("failed to set eww value: %w", err)
 	}
 
-	if d.config.EwwWindow != nil {
-		return d.openEwwWindow(*d.config.EwwWindow)
+	if window := d.cfg().EwwWindow; window != nil {
+		// Show the popup on the output of the newest notification's app,
+		// closing ones only count once none is left on screen
+		monitor := notifications[len(notifications)-1].Monitor
+		if latest, ok := d.state.GetLatest(); ok {
+			monitor = latest.Monitor
+		}
+		if monitor != "" {
+			return d.openEwwWindow(*window, "--screen", monitor)
+		}
+		return d.openEwwWindow(*window)
 	}
 
 	return nil
@@ -217,50 +1170,273 @@ func (d *Daemon) updateDisplay() error {
 func (d *Daemon) buildWidgetString(notifications []state.Notification) string {
 	var widgets []string
 
-	for _, notification := range notifications {
-		widget := d.buildNotificationWidget(notification)
-		// Wrap each notification in a container for consistent spacing
-		wrappedWidget := fmt.Sprintf("(box :class \"notification-container\" %s)", widget)
-		widgets = append(widgets, wrappedWidget)
+	// Slots keep their places no matter the order
+	if !d.cfg().StablePositions {
+		notifications = sortForDisplay(notifications, d.cfg().Order)
 	}
 
-	isVertical := d.config.NotificationOrientation == config.Vertical
-	result := d.buildWidgetWrapper(isVertical, strings.Join(widgets, ""))
+	if d.cfg().DisplayMode == config.DisplayStacked {
+		for _, stack := range groupConsecutiveByApp(notifications) {
+			widget := d.buildStackWidget(stack)
+			wrappedWidget := fmt.Sprintf("(box :class \"notification-container\" %s)", widget)
+			widgets = append(widgets, wrappedWidget)
+		}
+	} else if d.cfg().DisplayMode == config.DisplayGrouped {
+		for _, group := range groupByApp(notifications) {
+			for _, widget := range d.buildGroupWidgets(group) {
+				wrappedWidget := fmt.Sprintf("(box :class \"notification-container\" %s)", widget)
+				widgets = append(widgets, wrappedWidget)
+			}
+		}
+	} else if d.cfg().StablePositions {
+		widgets = d.buildSlotWidgets(notifications)
+	} else {
+		for _, notification := range notifications {
+			widget := d.buildNotificationWidget(notification, d.buildNotificationData(notification))
+			// Wrap each notification in a container for consistent spacing
+			wrappedWidget := fmt.Sprintf("(box :class \"notification-container\" %s)", widget)
+			widgets = append(widgets, wrappedWidget)
+		}
+	}
 
-	fmt.Printf("=== Final Widget String ===\n%s\n=== End ===\n", result)
+	isVertical := d.cfg().NotificationOrientation == config.Vertical
+	result := d.buildWidgetWrapper(isVertical, strings.Join(widgets, ""))
 
 	return result
 }
 
-func (d *Daemon) buildNotificationWidget(notification state.Notification) string {
-	// Create a proper JSON object
+// buildSlotWidgets places every notification in its fixed slot, padding
+// freed slots with empty boxes so the remaining widgets don't move
+func (d *Daemon) buildSlotWidgets(notifications []state.Notification) []string {
+	bySlot := make(map[int]state.Notification, len(notifications))
+	lastSlot := -1
+	for _, notification := range notifications {
+		// A freed slot may already hold a new notification
+		if _, taken := bySlot[notification.Slot]; taken && notification.Closing {
+			continue
+		}
+		bySlot[notification.Slot] = notification
+		lastSlot = max(lastSlot, notification.Slot)
+	}
+
+	var widgets []string
+	for slot := 0; slot <= lastSlot; slot++ {
+		notification, ok := bySlot[slot]
+		if !ok {
+			widgets = append(widgets, "(box :class \"notification-slot-empty\")")
+			continue
+		}
+		widget := d.buildNotificationWidget(notification, d.buildNotificationData(notification))
+		widgets = append(widgets, fmt.Sprintf("(box :class \"notification-container\" %s)", widget))
+	}
+
+	return widgets
+}
+
+// sortForDisplay returns the notifications in the configured order, the
+// state keeps them oldest first
+func sortForDisplay(notifications []state.Notification, order config.Order) []state.Notification {
+	if order == config.OrderOldestFirst {
+		return notifications
+	}
+
+	sorted := slices.Clone(notifications)
+	slices.Reverse(sorted)
+	if order == config.OrderUrgency {
+		slices.SortStableFunc(sorted, func(a, b state.Notification) int {
+			return cmp.Compare(dbus.GetUrgency(b.Hints), dbus.GetUrgency(a.Hints))
+		})
+	}
+	return sorted
+}
+
+// groupConsecutiveByApp splits notifications into runs of consecutive
+// entries sharing the same app name, preserving order
+func groupConsecutiveByApp(notifications []state.Notification) [][]state.Notification {
+	var stacks [][]state.Notification
+
+	for _, notification := range notifications {
+		last := len(stacks) - 1
+		if last >= 0 && stacks[last][0].AppName == notification.AppName {
+			stacks[last] = append(stacks[last], notification)
+			continue
+		}
+		stacks = append(stacks, []state.Notification{notification})
+	}
+
+	return stacks
+}
+
+// groupByApp splits notifications into one group per app, ordered by where
+// each app first appears
+func groupByApp(notifications []state.Notification) [][]state.Notification {
+	var groups [][]state.Notification
+	index := make(map[string]int)
+
+	for _, notification := range notifications {
+		if i, ok := index[notification.AppName]; ok {
+			groups[i] = append(groups[i], notification)
+			continue
+		}
+		index[notification.AppName] = len(groups)
+		groups = append(groups, []state.Notification{notification})
+	}
+
+	return groups
+}
+
+// buildGroupWidgets renders an app's notifications as a single entry for
+// the newest one, or as all of them, newest first, once the group is
+// expanded. Every widget carries the group so eww can draw the stack.
+func (d *Daemon) buildGroupWidgets(group []state.Notification) []string {
+	group = slices.SortedStableFunc(slices.Values(group), func(a, b state.Notification) int {
+		return b.Timestamp.Compare(a.Timestamp)
+	})
+	appName := group[0].AppName
+	expanded := len(group) > 1 && d.state.IsExpanded(appName)
+
+	members := make([]map[string]any, 0, len(group))
+	for _, notification := range group {
+		members = append(members, map[string]any{
+			"id":      notification.Id,
+			"summary": notification.Summary,
+		})
+	}
+	groupData := map[string]any{
+		"app_name":       appName,
+		"count":          len(group),
+		"expanded":       expanded,
+		"latest_summary": group[0].Summary,
+		"members":        members,
+	}
+
+	shown := group[:1]
+	if expanded {
+		shown = group
+	}
+
+	widgets := make([]string, 0, len(shown))
+	for position, notification := range shown {
+		data := d.buildNotificationData(notification)
+		if !expanded {
+			// A collapsed group stands for all of its members
+			data["selected"] = slices.ContainsFunc(group, d.isSelected)
+		}
+		data["group"] = groupData
+		data["group_position"] = position
+		widgets = append(widgets, d.buildNotificationWidget(notification, data))
+	}
+	return widgets
+}
+
+// buildStackWidget renders a run of notifications from one app as a single
+// widget, showing the entry selected by the app's stack cursor
+func (d *Daemon) buildStackWidget(stack []state.Notification) string {
+	// The cursor counts back from the newest whatever the display order
+	stack = slices.SortedStableFunc(slices.Values(stack), func(a, b state.Notification) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+	cursor := d.state.GetStackCursor(stack[0].AppName) % len(stack)
+	shown := stack[len(stack)-1-cursor]
+
+	data := d.buildNotificationData(shown)
+	// The cursor may be on an entry hidden in the stack
+	data["selected"] = slices.ContainsFunc(stack, d.isSelected)
+	data["stack_size"] = len(stack)
+	data["stack_position"] = cursor
+	data["stack_badge"] = ""
+	if len(stack) > 1 {
+		data["stack_badge"] = fmt.Sprintf("(+%d)", len(stack)-1)
+	}
+
+	return d.buildNotificationWidget(shown, data)
+}
+
+// buildNotificationData builds the JSON object handed to the widget
+func (d *Daemon) buildNotificationData(notification state.Notification) map[string]any {
+	animation := d.cfg().Animation
 	notificationData := map[string]any{
-		"id":       notification.Id,
-		"summary":  notification.Summary,
-		"body":     notification.Body,
-		"app_name": notification.AppName,
-		"app_icon": notification.AppIcon,
-		"hints":    notification.Hints,
-		"actions":  d.buildActionsArray(notification.Actions),
+		"id":                 notification.Id,
+		"summary":            notification.Summary,
+		"body":               notification.Body,
+		"app_name":           notification.AppName,
+		"app_display_name":   notification.AppDisplayName(),
+		"category":           notificationCategory(notification),
+		"links":              notificationLinks(notification),
+		"app_icon":           notification.AppIcon,
+		"hints":              notification.Hints,
+		"actions":            d.buildActionsArray(notification),
+		"reply":              replyData(notification),
+		"compact":            notification.Compact,
+		"count":              notification.Copies(),
+		"pinned":             notification.Pinned,
+		"paused":             notification.Paused,
+		"selected":           d.isSelected(notification),
+		"time_left_fraction": notification.TimeLeftFraction(d.state.GetPausedAt()),
+		"state":              displayState(notification),
+		"created_at":         notification.CreatedAt().Unix(),
+		"expires_at":         d.expiresAt(notification),
+		"timeout_ms":         notification.Timeout.Milliseconds(),
+		"animation": map[string]any{
+			"reveal_duration":    animation.RevealDuration,
+			"dismiss_duration":   animation.DismissDuration,
+			"reveal_transition":  animation.RevealTransition,
+			"dismiss_transition": animation.DismissTransition,
+			"closing_duration":   animation.ClosingDuration,
+		},
+	}
+
+	if notification.ExtraClass != nil {
+		notificationData["extra_class"] = *notification.ExtraClass
 	}
 
+	if progress, ok := dbus.GetProgress(notification.Hints); ok {
+		notificationData["progress"] = progress
+	}
+
+	// Without an image the widget can still fall back to the app icon
+	if notification.Image != "" {
+		notificationData["image"] = notification.Image
+	} else if notification.AppIcon != "" {
+		notificationData["image"] = notification.AppIcon
+	}
+
+	return notificationData
+}
+
+// isSelected reports whether the keyboard selection cursor is on notification
+func (d *Daemon) isSelected(notification state.Notification) bool {
+	return !notification.Closing && notification.Id == d.state.GetSelectedId()
+}
+
+// displayState tells the widget whether to play its exit animation
+func displayState(notification state.Notification) string {
+	if notification.Closing {
+		return "closing"
+	}
+	return "open"
+}
+
+// expiresAt is the Unix time a notification times out, nil while it is not
+// counting down
+func (d *Daemon) expiresAt(notification state.Notification) any {
+	if at, ok := notification.ExpiresAt(d.state.GetPausedAt()); ok {
+		return at.Unix()
+	}
+	return nil
+}
+
+func (d *Daemon) buildNotificationWidget(notification state.Notification, notificationData map[string]any) string {
 	// Convert to JSON string
 	jsonBytes, err := json.Marshal(notificationData)
 	if err != nil {
-		log.Printf("ERROR: Failed to marshal notification to JSON: %v", err)
+		slog.Error("Failed to marshal notification to JSON", "id", notification.Id, "err", err)
 		return ""
 	}
 
 	// Escape the JSON string for use in eww
 	jsonString := d.escapeJsonForEww(string(jsonBytes))
 
-	// Widget selector - directly return the appropriate widget call
-	if notifyType, exists := notification.Hints["type"]; exists {
-		if typeStr, ok := notifyType.(string); ok && typeStr == "battery" {
-			return fmt.Sprintf("(battery-notification :notification \"%s\")", jsonString)
-		}
-	}
-
 	// Check if a custom widget is specified
 	if notification.Widget != nil {
 		return fmt.Sprintf("(%s :notification \"%s\")", *notification.Widget, jsonString)
@@ -270,21 +1446,115 @@ func (d *Daemon) buildNotificationWidget(notification state.Notification) string
 	return fmt.Sprintf("(base-notification :notification \"%s\")", jsonString)
 }
 
-func (d *Daemon) buildActionsArray(actions []string) []map[string]string {
+func (d *Daemon) buildActionsArray(notification state.Notification) []map[string]string {
+	actions := notification.Actions
+	cfg := d.cfg().Actions
+
 	var actionArray []map[string]string
+	var defaultAction map[string]string
 
 	for i := 0; i < len(actions); i += 2 {
 		if i+1 < len(actions) {
-			actionArray = append(actionArray, map[string]string{
+			// Shown as a text field, see replyData
+			if actions[i] == ReplyActionKey || cfg.IsHidden(notification.AppName, actions[i]) {
+				continue
+			}
+
+			action := map[string]string{
 				"key":  actions[i],
 				"name": actions[i+1],
+			}
+
+			if actions[i] == "default" && cfg.DefaultPosition != config.DefaultKeep {
+				defaultAction = action
+				continue
+			}
+			actionArray = append(actionArray, action)
+		}
+	}
+
+	if defaultAction != nil {
+		if cfg.DefaultPosition == config.DefaultFirst {
+			actionArray = append([]map[string]string{defaultAction}, actionArray...)
+		} else {
+			actionArray = append(actionArray, defaultAction)
+		}
+	}
+
+	if cfg.Max > 0 && len(actionArray) > int(cfg.Max) {
+		actionArray = actionArray[:cfg.Max]
+	}
+
+	// Appended after the cap so these buttons are always available
+	if cfg.InjectExtend && notification.Timeout > 0 {
+		actionArray = append(actionArray, map[string]string{
+			"key":  ExtendActionKey,
+			"name": cfg.ExtendLabel,
+		})
+	}
+	if cfg.InjectLinks {
+		for i, link := range notification.Links {
+			actionArray = append(actionArray, map[string]string{
+				"key":  OpenURLActionKey + strconv.Itoa(i),
+				"name": link.Text,
 			})
 		}
 	}
+	if cfg.InjectDismiss {
+		actionArray = append(actionArray, map[string]string{
+			"key":  DismissActionKey,
+			"name": cfg.DismissLabel,
+		})
+	}
 
 	return actionArray
 }
 
+// notificationType looks up the configured type named by the end-type or
+// type hint
+func (d *Daemon) notificationType(hints map[string]any) (*string, config.NotificationType, bool) {
+	name, ok := dbus.GetStringHint(hints, dbus.HintKeyNotifyType)
+	if !ok {
+		name, ok = dbus.GetStringHint(hints, "type")
+	}
+	if !ok {
+		return nil, config.NotificationType{}, false
+	}
+
+	typeCfg, exists := d.cfg().Types[name]
+	return &name, typeCfg, exists
+}
+
+// notificationLinks returns the body's links, never nil so widgets always
+// get an array
+func notificationLinks(notification state.Notification) []state.Link {
+	if notification.Links == nil {
+		return []state.Link{}
+	}
+	return notification.Links
+}
+
+// notificationCategory returns the category hint, empty when not sent
+func notificationCategory(notification state.Notification) string {
+	category, _ := dbus.GetStringHint(notification.Hints, dbus.HintKeyCategory)
+	return category
+}
+
+// extraClassFromHints returns the end-class hint if the config allows it
+func (d *Daemon) extraClassFromHints(hints map[string]any) *string {
+	class, ok := dbus.GetStringHint(hints, dbus.HintKeyClass)
+	if !ok || class == "" {
+		return nil
+	}
+
+	if !d.cfg().IsClassAllowed(class) {
+		slog.Debug("Ignoring class, not in allowed-classes", "class", class)
+		return nil
+	}
+
+	return &class
+}
+
 func (d *Daemon) escapeJsonForEww(jsonStr string) string {
 	// Escape quotes and backslashes for eww
 	jsonStr = strings.ReplaceAll(jsonStr, "\\", "\\\\")
@@ -307,12 +1577,13 @@ func (d *Daemon) cleanupLoop() {
 	for {
 		select {
 		case <-ticker.C:
+			d.pruneImages()
+			if d.state.IsPaused() {
+				continue
+			}
 			expiredIds := d.state.CleanupExpiredNotifications()
 			for _, id := range expiredIds {
-				if cancel, exists := d.timeoutTasks[id]; exists {
-					cancel()
-					delete(d.timeoutTasks, id)
-				}
+				d.timers.cancel(id)
 				d.dbusServer.EmitNotificationClosed(id, state.Expired)
 			}
 			if len(expiredIds) > 0 {
@@ -324,30 +1595,31 @@ func (d *Daemon) cleanupLoop() {
 	}
 }
 
-// Eww command helpers
-func (d *Daemon) setEwwValue(variable, value string) error {
-	cmd := exec.Command("eww", "update", fmt.Sprintf("%s=%s", variable, value))
-	_, err := cmd.CombinedOutput()
-	if err != nil {
-		return err
-	}
-	return nil
-}
+// progressLoop republishes the notifications on every tick so widgets can
+// render a shrinking time_left_fraction bar
+func (d *Daemon) progressLoop(tick time.Duration) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
 
-func (d *Daemon) openEwwWindow(window string) error {
-	cmd := exec.Command("eww", "open", window)
-	_, err := cmd.CombinedOutput()
-	if err != nil {
-		return err
+	for {
+		select {
+		case <-ticker.C:
+			if !d.state.IsPaused() && d.hasRunningTimeouts() {
+				d.updateDisplay()
+			}
+		case <-d.ctx.Done():
+			return
+		}
 	}
-	return nil
 }
 
-func (d *Daemon) closeEwwWindow(window string) error {
-	cmd := exec.Command("eww", "close", window)
-	_, err := cmd.CombinedOutput()
-	if err != nil {
-		return err
+// hasRunningTimeouts reports whether any displayed notification is counting
+// down, nothing needs republishing otherwise
+func (d *Daemon) hasRunningTimeouts() bool {
+	for _, notification := range d.state.GetNotifications() {
+		if notification.Timeout > 0 {
+			return true
+		}
 	}
-	return nil
+	return false
 }
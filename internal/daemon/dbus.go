@@ -3,11 +3,14 @@ package daemon
 import (
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
 	"github.com/godbus/dbus/v5"
 	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
 
+	"github.com/cheezecakee/eww-notify-go/internal/config"
 	"github.com/cheezecakee/eww-notify-go/internal/state"
 )
 
@@ -18,13 +21,15 @@ const (
 )
 
 type NotificationServer struct {
-	conn   *dbus.Conn
-	state  *state.NotificationState
-	daemon *Daemon // Add reference to daemon
+	conn    *dbus.Conn
+	state   *state.NotificationState
+	daemon  *Daemon // Add reference to daemon
+	monitor bool    // Mirroring another instance, must not emit signals
+	props   *prop.Properties
 }
 
 func NewNotificationServer(notificationState *state.NotificationState) (*NotificationServer, error) {
-	log.Println("DEBUG: Creating NotificationServer")
+	slog.Debug("Creating NotificationServer")
 	conn, err := dbus.ConnectSessionBus()
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to session bus: %w", err)
@@ -36,61 +41,289 @@ func NewNotificationServer(notificationState *state.NotificationState) (*Notific
 	}
 
 	notificationState.DbusConn = conn
-	log.Println("DEBUG: NotificationServer created successfully")
+	slog.Debug("NotificationServer created successfully")
 
 	return server, nil
 }
 
 func (ns *NotificationServer) SetupDBusService() error {
-	log.Println("DEBUG: Setting up DBus service")
+	slog.Debug("Setting up DBus service")
+	if err := ns.exportControl(); err != nil {
+		return err
+	}
+
+	if ns.daemon.cfg().DBusMode == config.DBusMonitor {
+		return ns.setupMonitor()
+	}
+
 	reply, err := ns.conn.RequestName(NotificationServiceName, dbus.NameFlagAllowReplacement|dbus.NameFlagReplaceExisting)
 	if err != nil {
 		return fmt.Errorf("failed to request service name: %w", err)
 	}
 
-	if reply != dbus.RequestNameReplyPrimaryOwner {
-		return fmt.Errorf("failed to become primary owner of %s", NotificationServiceName)
+	switch {
+	case reply == dbus.RequestNameReplyPrimaryOwner:
+		slog.Debug("Acquired service name", "name", NotificationServiceName)
+	case reply == dbus.RequestNameReplyInQueue && ns.daemon.cfg().OnNameLost == config.NameLostQueue:
+		slog.Info("Service name is owned by another daemon, waiting in the queue", "name", NotificationServiceName)
+	default:
+		return fmt.Errorf("failed to become primary owner of %s, another notification daemon is running (start with -replace to take over)", NotificationServiceName)
 	}
 
-	log.Printf("DEBUG: Successfully acquired service name: %s", NotificationServiceName)
+	if err := ns.watchName(); err != nil {
+		return err
+	}
 
 	err = ns.conn.Export(ns, NotificationObjectPath, NotificationInterface)
 	if err != nil {
 		return fmt.Errorf("failed to export notification interface: %w", err)
 	}
 
+	// Inhibited mirrors Do-Not-Disturb like GNOME Shell does
+	ns.props, err = prop.Export(ns.conn, NotificationObjectPath, prop.Map{
+		NotificationInterface: {
+			"Inhibited": {Value: ns.daemon.dndEnabled(), Emit: prop.EmitTrue},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export properties interface: %w", err)
+	}
+
 	err = ns.conn.Export(introspect.Introspectable(ns.introspectData()), NotificationObjectPath, "org.freedesktop.DBus.Introspectable")
 	if err != nil {
 		return fmt.Errorf("failed to export introspection interface: %w", err)
 	}
 
-	log.Println("DEBUG: DBus service setup complete")
+	// Sandboxed apps still work through the classic API without it
+	if ns.daemon.cfg().PortalBackend {
+		if err := ns.setupPortal(); err != nil {
+			slog.Warn("Portal backend disabled", "err", err)
+		}
+	}
+
+	slog.Debug("DBus service setup complete")
 	return nil
 }
 
+// watchName follows ownership of the service name, so losing it to another
+// daemon shuts this one down or leaves it waiting in the queue instead of
+// carrying on with an export nobody calls
+func (ns *NotificationServer) watchName() error {
+	for _, member := range []string{"NameLost", "NameAcquired"} {
+		err := ns.conn.AddMatchSignal(
+			dbus.WithMatchInterface("org.freedesktop.DBus"),
+			dbus.WithMatchMember(member),
+			dbus.WithMatchArg(0, NotificationServiceName),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to watch %s: %w", NotificationServiceName, err)
+		}
+	}
+
+	signals := make(chan *dbus.Signal, 8)
+	ns.conn.Signal(signals)
+
+	go func() {
+		defer ns.conn.RemoveSignal(signals)
+		for {
+			select {
+			case signal, ok := <-signals:
+				if !ok {
+					return
+				}
+				runRecovered("bus name watcher", func() { ns.handleNameSignal(signal) })
+			case <-ns.daemon.ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (ns *NotificationServer) handleNameSignal(signal *dbus.Signal) {
+	if len(signal.Body) == 0 {
+		return
+	}
+	if name, _ := signal.Body[0].(string); name != NotificationServiceName {
+		return
+	}
+
+	switch signal.Name {
+	case "org.freedesktop.DBus.NameAcquired":
+		slog.Info("Acquired service name", "name", NotificationServiceName)
+	case "org.freedesktop.DBus.NameLost":
+		if ns.daemon.cfg().OnNameLost == config.NameLostExit {
+			slog.Info("Another daemon took over the service name, shutting down", "name", NotificationServiceName)
+			ns.daemon.shutdown()
+			return
+		}
+
+		// Replaced owners are queued unless they asked not to be, ask
+		// again in case we were dropped
+		reply, err := ns.conn.RequestName(NotificationServiceName, dbus.NameFlagAllowReplacement)
+		if err != nil {
+			slog.Error("Failed to queue for the service name", "name", NotificationServiceName, "err", err)
+			ns.daemon.shutdown()
+			return
+		}
+		if reply == dbus.RequestNameReplyPrimaryOwner {
+			slog.Info("Acquired service name", "name", NotificationServiceName)
+			return
+		}
+		slog.Info("Another daemon took over the service name, waiting in the queue", "name", NotificationServiceName)
+	}
+}
+
+// WaitForNameRelease blocks until nobody owns the notification service name
+// or the timeout passes
+func WaitForNameRelease(timeout time.Duration) error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to session bus: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		var owned bool
+		err := conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, NotificationServiceName).Store(&owned)
+		if err != nil {
+			return fmt.Errorf("failed to query owner of %s: %w", NotificationServiceName, err)
+		}
+		if !owned {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s still owned after %s", NotificationServiceName, timeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// setupMonitor mirrors Notify calls addressed to whichever daemon owns the
+// bus name instead of competing for it, so secondary instances can display
+// the same notifications
+func (ns *NotificationServer) setupMonitor() error {
+	slog.Debug("Setting up DBus monitor")
+	ns.monitor = true
+
+	conn, err := dbus.SessionBusPrivate()
+	if err != nil {
+		return fmt.Errorf("failed to open monitor connection: %w", err)
+	}
+	if err := conn.Auth(nil); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to authenticate monitor connection: %w", err)
+	}
+	if err := conn.Hello(); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to register monitor connection: %w", err)
+	}
+
+	rules := []string{"type='method_call',interface='" + NotificationInterface + "',member='Notify'"}
+	call := conn.BusObject().Call("org.freedesktop.DBus.Monitoring.BecomeMonitor", 0, rules, uint32(0))
+	if call.Err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to become monitor: %w", call.Err)
+	}
+
+	messages := make(chan *dbus.Message, 32)
+	conn.Eavesdrop(messages)
+
+	go func() {
+		defer conn.Close()
+		for {
+			select {
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				runRecovered("monitor", func() { ns.mirrorNotify(msg) })
+			case <-ns.daemon.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	slog.Debug("DBus monitor setup complete")
+	return nil
+}
+
+// mirrorNotify feeds an eavesdropped Notify call into the local daemon
+func (ns *NotificationServer) mirrorNotify(msg *dbus.Message) {
+	var (
+		appName, appIcon, summary, body string
+		replacesId                      uint32
+		actions                         []string
+		hints                           map[string]dbus.Variant
+		expireTimeout                   int32
+	)
+
+	if err := dbus.Store(msg.Body, &appName, &replacesId, &appIcon, &summary, &body, &actions, &hints, &expireTimeout); err != nil {
+		slog.Error("Failed to decode mirrored Notify call", "err", err)
+		return
+	}
+
+	sender, _ := msg.Headers[dbus.FieldSender].Value().(string)
+
+	// Replace IDs belong to the owning instance and mean nothing here
+	if _, err := ns.Notify(dbus.Sender(sender), appName, 0, appIcon, summary, body, actions, hints, expireTimeout); err != nil {
+		slog.Error("Failed to mirror notification", "err", err)
+	}
+}
+
 func (ns *NotificationServer) Close() error {
 	return ns.conn.Close()
 }
 
 func (ns *NotificationServer) GetServerInformation() (string, string, string, string, *dbus.Error) {
-	log.Println("DEBUG: GetServerInformation called")
+	slog.Debug("GetServerInformation called")
 	return "golang-notification-daemon", "eww", "1.2.0", "1.2", nil
 }
 
-func (ns *NotificationServer) GetCapabilities() ([]string, *dbus.Error) {
-	log.Println("DEBUG: GetCapabilities called")
-	capabilities := []string{
+func (ns *NotificationServer) GetCapabilities() (_ []string, dbusErr *dbus.Error) {
+	defer recoverCall("GetCapabilities", &dbusErr)
+	slog.Debug("GetCapabilities called")
+	if ns.daemon == nil {
+		return capabilities(config.DefaultConfig), nil
+	}
+	return capabilities(ns.daemon.cfg()), nil
+}
+
+// capabilities lists what the daemon currently supports, so clients only
+// rely on features that are actually enabled
+func capabilities(cfg config.Config) []string {
+	caps := []string{
 		"body",
 		"hints",
-		"persistence",
 		"icon-static",
-		"actions-icons",
 		"actions",
+		"inline-reply",
+	}
+
+	// Closed notifications are kept around to be restored from history
+	if cfg.HistorySize > 0 {
+		caps = append(caps, "persistence")
+	}
+
+	if cfg.BodyMarkup == config.MarkupTranslate {
+		caps = append(caps, "body-markup")
+	}
+
+	// Raw bodies reach the widget as sent, links only work if it renders them
+	if cfg.BodyMarkup != config.MarkupRaw {
+		caps = append(caps, "body-hyperlinks")
+	}
+
+	if cfg.Sound.Enabled {
+		caps = append(caps, "sound")
 	}
-	return capabilities, nil
+
+	return caps
 }
 
 func (ns *NotificationServer) Notify(
+	sender dbus.Sender,
 	appName string,
 	replacesId uint32,
 	appIcon string,
@@ -99,24 +332,25 @@ func (ns *NotificationServer) Notify(
 	actions []string,
 	hints map[string]dbus.Variant,
 	expireTimeout int32,
-) (uint32, *dbus.Error) {
-	log.Printf("DEBUG: Notify called - App: %s, Summary: %s, Body: %s", appName, summary, body)
-	log.Printf("DEBUG: ReplaceID: %d, ExpireTimeout: %d", replacesId, expireTimeout)
-	log.Printf("DEBUG: Actions: %v", actions)
+) (_ uint32, dbusErr *dbus.Error) {
+	defer recoverCall("Notify", &dbusErr)
+	slog.Debug("Notify called", "app", appName, "summary", summary, "body", body,
+		"replaces_id", replacesId, "expire_timeout", expireTimeout, "actions", actions)
 
 	// Convert dbus.Variant hints to internal format
 	internalHints := make(map[string]any)
 	for key, variant := range hints {
 		internalHints[key] = variant.Value()
-		log.Printf("DEBUG: Hint %s = %v (type: %T)", key, variant.Value(), variant.Value())
+		slog.Debug("Hint", "key", key, "value", variant.Value(), "type", fmt.Sprintf("%T", variant.Value()))
 	}
 
 	if ns.daemon == nil {
-		log.Println("ERROR: Daemon reference is nil!")
+		slog.Error("Daemon reference is nil")
 		return 0, dbus.MakeFailedError(fmt.Errorf("daemon not initialized"))
 	}
 
 	notificationId, err := ns.daemon.HandleNotification(
+		string(sender),
 		appName,
 		replacesId,
 		appIcon,
@@ -127,17 +361,18 @@ func (ns *NotificationServer) Notify(
 		expireTimeout,
 	)
 	if err != nil {
-		log.Printf("ERROR: Failed to handle notification: %v", err)
+		slog.Error("Failed to handle notification", "app", appName, "err", err)
 		return 0, dbus.MakeFailedError(err)
 	}
 
-	log.Printf("DEBUG: Notify returning ID: %d", notificationId)
+	slog.Debug("Notify returning", "id", notificationId)
 	return notificationId, nil
 }
 
-func (ns *NotificationServer) CloseNotification(id uint32) *dbus.Error {
-	log.Printf("DEBUG: CloseNotification called for ID: %d", id)
-	found := ns.state.RemoveNotification(id)
+func (ns *NotificationServer) CloseNotification(id uint32) (dbusErr *dbus.Error) {
+	defer recoverCall("CloseNotification", &dbusErr)
+	slog.Debug("CloseNotification called", "id", id)
+	found := ns.state.RemoveNotification(id, state.CloseNotification)
 	if !found {
 		return dbus.MakeFailedError(fmt.Errorf("notification with ID %d not found", id))
 	}
@@ -149,9 +384,21 @@ func (ns *NotificationServer) CloseNotification(id uint32) *dbus.Error {
 	return nil
 }
 
+// setInhibited updates the Inhibited property, emitting PropertiesChanged
+func (ns *NotificationServer) setInhibited(inhibited bool) {
+	if ns.props == nil {
+		return
+	}
+	ns.props.SetMust(NotificationInterface, "Inhibited", inhibited)
+}
+
 // Signal emission methods
 func (ns *NotificationServer) EmitActionInvoked(id uint32, actionKey string) error {
-	log.Printf("DEBUG: Emitting ActionInvoked signal for ID %d, action: %s", id, actionKey)
+	slog.Debug("Emitting ActionInvoked signal", "id", id, "action", actionKey)
+	ns.daemon.events.publish(Event{Event: "action", Id: id, ActionKey: actionKey})
+	if ns.monitor {
+		return nil
+	}
 	return ns.conn.Emit(
 		NotificationObjectPath,
 		NotificationInterface+".ActionInvoked",
@@ -160,21 +407,52 @@ func (ns *NotificationServer) EmitActionInvoked(id uint32, actionKey string) err
 	)
 }
 
+func (ns *NotificationServer) EmitActivationToken(id uint32, token string) error {
+	slog.Debug("Emitting ActivationToken signal", "id", id)
+	if ns.monitor {
+		return nil
+	}
+	return ns.conn.Emit(
+		NotificationObjectPath,
+		NotificationInterface+".ActivationToken",
+		id,
+		token,
+	)
+}
+
+func (ns *NotificationServer) EmitNotificationReplied(id uint32, text string) error {
+	slog.Debug("Emitting NotificationReplied signal", "id", id)
+	ns.daemon.events.publish(Event{Event: "reply", Id: id, Text: text})
+	if ns.monitor {
+		return nil
+	}
+	return ns.conn.Emit(
+		NotificationObjectPath,
+		NotificationInterface+".NotificationReplied",
+		id,
+		text,
+	)
+}
+
 func (ns *NotificationServer) EmitNotificationClosed(id uint32, reason state.NotificationCloseReason) error {
-	reasonId := uint32(reason) + 1
-	log.Printf("DEBUG: Emitting NotificationClosed signal for ID %d, reason: %s (%d)", id, reason.String(), reasonId)
+	slog.Debug("Emitting NotificationClosed signal", "id", id, "reason", reason.String(), "reason_code", reason.Code())
+	ns.daemon.events.publish(Event{Event: "close", Id: id, Reason: reason.String()})
+	if ns.monitor {
+		return nil
+	}
 
 	return ns.conn.Emit(
 		NotificationObjectPath,
 		NotificationInterface+".NotificationClosed",
 		id,
-		reasonId,
+		reason.Code(),
 	)
 }
 
 // Helper methods
 func (ns *NotificationServer) introspectData() string {
-	return `<interface name="org.freedesktop.Notifications">
+	return `<node>
+	<interface name="org.freedesktop.Notifications">
 		<method name="GetCapabilities">
 			<arg direction="out" name="capabilities" type="as"/>
 		</method>
@@ -206,7 +484,31 @@ func (ns *NotificationServer) introspectData() string {
 			<arg name="id" type="u"/>
 			<arg name="action_key" type="s"/>
 		</signal>
-	</interface>`
+		<signal name="ActivationToken">
+			<arg name="id" type="u"/>
+			<arg name="activation_token" type="s"/>
+		</signal>
+		<signal name="NotificationReplied">
+			<arg name="id" type="u"/>
+			<arg name="text" type="s"/>
+		</signal>
+		<property name="Inhibited" type="b" access="read"/>
+	</interface>` + prop.IntrospectDataString + `
+</node>`
+}
+
+// senderPid asks the bus for the process ID behind a unique connection name
+func (ns *NotificationServer) senderPid(sender string) (uint32, error) {
+	if sender == "" {
+		return 0, fmt.Errorf("notification has no D-Bus sender")
+	}
+
+	var pid uint32
+	err := ns.conn.BusObject().Call("org.freedesktop.DBus.GetConnectionUnixProcessID", 0, sender).Store(&pid)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get pid of %s: %w", sender, err)
+	}
+	return pid, nil
 }
 
 func (ns *NotificationServer) GetConnection() *dbus.Conn {
@@ -0,0 +1,135 @@
+package daemon
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/cheezecakee/eww-notify-go/internal/config"
+	"github.com/cheezecakee/eww-notify-go/internal/state"
+)
+
+// shouldQueueForDnd reports whether a notification is held back because
+// Do-Not-Disturb is on or the quiet hours are active
+func (d *Daemon) shouldQueueForDnd(urgency string) bool {
+	cfg := d.cfg().Dnd
+	if d.state.IsDnd() {
+		return !(urgency == "critical" && cfg.CriticalBypass)
+	}
+	if d.checkDndSchedule() {
+		return !cfg.Schedule.Allows(urgency)
+	}
+	return false
+}
+
+// SetDnd enables or disables Do-Not-Disturb. Notifications queued while it
+// was on are shown or moved to history depending on the config.
+func (d *Daemon) SetDnd(enabled bool) error {
+	queued, changed := d.state.SetDnd(enabled)
+	if !changed {
+		return nil
+	}
+
+	slog.Info("Do-Not-Disturb " + dndLabel(enabled))
+	d.publishDnd()
+
+	return d.releaseDndQueue(queued)
+}
+
+// checkDndSchedule switches scheduled Do-Not-Disturb on or off as the quiet
+// hours begin and end, reporting whether they are active
+func (d *Daemon) checkDndSchedule() bool {
+	active := d.cfg().Dnd.Schedule.Active(time.Now())
+
+	queued, changed := d.state.SetScheduledDnd(active)
+	if !changed {
+		return active
+	}
+
+	slog.Info("Quiet hours " + dndLabel(active))
+	d.publishDnd()
+
+	if err := d.releaseDndQueue(queued); err != nil {
+		slog.Error("Failed to show notifications held during quiet hours", "err", err)
+	}
+	return active
+}
+
+// dndScheduleLoop notices the end of quiet hours even when no notification
+// arrives to trigger checkDndSchedule
+func (d *Daemon) dndScheduleLoop() {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.checkDndSchedule()
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// dndEnabled reports whether Do-Not-Disturb is on, by hand or by the
+// quiet hours
+func (d *Daemon) dndEnabled() bool {
+	return d.state.IsDnd() || d.state.IsScheduledDnd()
+}
+
+// publishDnd announces the effective Do-Not-Disturb state to subscribers
+// and eww
+func (d *Daemon) publishDnd() {
+	enabled := d.dndEnabled()
+	d.events.publish(Event{Event: "dnd-change", Enabled: &enabled})
+	d.dbusServer.setInhibited(enabled)
+
+	if err := d.setEwwValue("end-dnd", strconv.FormatBool(enabled)); err != nil {
+		slog.Error("Failed to set end-dnd", "err", err)
+	}
+}
+
+// releaseDndQueue shows the notifications held back by Do-Not-Disturb or
+// moves them to history depending on the config
+func (d *Daemon) releaseDndQueue(queued []state.Notification) error {
+	if len(queued) == 0 {
+		return nil
+	}
+
+	if d.cfg().Dnd.OnDisable == config.DndToHistory {
+		for _, notification := range queued {
+			d.state.AddHistory(notification, state.Undefined)
+		}
+		return nil
+	}
+
+	// Queued notifications start their lifetime when they are finally shown
+	for _, notification := range queued {
+		notification.Timestamp = time.Now()
+		d.state.AddNotification(notification)
+		if notification.Timeout > 0 && !d.state.IsPaused() {
+			d.scheduleTimeout(notification.Id, notification.Timeout)
+		}
+	}
+	return d.updateDisplay()
+}
+
+// ToggleDnd flips Do-Not-Disturb
+func (d *Daemon) ToggleDnd() error {
+	return d.SetDnd(!d.state.IsDnd())
+}
+
+// DndStatus describes the Do-Not-Disturb state for the status command
+func (d *Daemon) DndStatus() string {
+	d.checkDndSchedule()
+	return fmt.Sprintf("dnd: %s\nquiet-hours: %s\nqueued: %d\n",
+		dndLabel(d.state.IsDnd()), dndLabel(d.state.IsScheduledDnd()), d.state.DndQueueLength())
+}
+
+func dndLabel(enabled bool) string {
+	if enabled {
+		return "on"
+	}
+	return "off"
+}
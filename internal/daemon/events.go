@@ -0,0 +1,66 @@
+package daemon
+
+import (
+	"sync"
+	"time"
+)
+
+// Event is a single entry of the subscription stream
+type Event struct {
+	Event     string `json:"event"`
+	Id        uint32 `json:"id,omitempty"`
+	AppName   string `json:"app_name,omitempty"`
+	Summary   string `json:"summary,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	ActionKey string `json:"action_key,omitempty"`
+	Text      string `json:"text,omitempty"`
+	Enabled   *bool  `json:"enabled,omitempty"`
+	Time      int64  `json:"time"`
+}
+
+// subscriberBuffer is how many events a slow subscriber may fall behind
+// before events are dropped for it
+const subscriberBuffer = 64
+
+// eventBus fans daemon events out to IPC subscribers
+type eventBus struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+// subscribe registers a new subscriber, the returned function unregisters it
+func (b *eventBus) subscribe() (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers == nil {
+		b.subscribers = make(map[chan Event]struct{})
+	}
+
+	ch := make(chan Event, subscriberBuffer)
+	b.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// publish delivers an event to every subscriber without blocking
+func (b *eventBus) publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event.Time = time.Now().Unix()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
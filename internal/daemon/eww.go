@@ -0,0 +1,177 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// errEwwDegraded is returned instead of running eww while it is unhealthy
+var errEwwDegraded = errors.New("eww is unavailable, running in degraded mode")
+
+// ewwWatchdog counts consecutive eww failures and switches the daemon into
+// degraded mode once they pile up, so a dead eww isn't exec'd on every update
+type ewwWatchdog struct {
+	mu       sync.Mutex
+	failures uint32
+	degraded bool
+}
+
+// Eww command helpers
+func (d *Daemon) setEwwValue(variable, value string) error {
+	return d.runEww("update", fmt.Sprintf("%s=%s", variable, value))
+}
+
+func (d *Daemon) openEwwWindow(window string, args ...string) error {
+	return d.runEww(append([]string{"open", window}, args...)...)
+}
+
+func (d *Daemon) closeEwwWindow(window string) error {
+	return d.runEww("close", window)
+}
+
+// ewwCommand builds an eww invocation honoring the configured binary,
+// extra arguments and config dir
+func (d *Daemon) ewwCommand(args ...string) *exec.Cmd {
+	cfg := d.cfg()
+
+	var fullArgs []string
+	if cfg.EwwConfigDir != nil {
+		fullArgs = append(fullArgs, "--config", *cfg.EwwConfigDir)
+	}
+	fullArgs = append(fullArgs, cfg.EwwArgs...)
+	fullArgs = append(fullArgs, args...)
+
+	return exec.Command(cfg.EwwBinary, fullArgs...)
+}
+
+// pingEww reports whether the eww daemon is up
+func (d *Daemon) pingEww() bool {
+	return d.ewwCommand("ping").Run() == nil
+}
+
+// startEww launches the eww daemon if it isn't running and waits for it to
+// answer
+func (d *Daemon) startEww() error {
+	if d.pingEww() {
+		return nil
+	}
+
+	slog.Info("Starting eww daemon")
+	if err := d.ewwCommand("daemon").Run(); err != nil {
+		return fmt.Errorf("failed to start eww daemon: %w", err)
+	}
+
+	for range 20 {
+		if d.pingEww() {
+			return nil
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	return fmt.Errorf("eww daemon did not come up")
+}
+
+// superviseEww restarts eww when it goes away and reopens the notification
+// window once it is back
+func (d *Daemon) superviseEww(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if d.pingEww() {
+				continue
+			}
+
+			slog.Warn("eww daemon is gone, restarting it")
+			if err := d.startEww(); err != nil {
+				slog.Error("Failed to restart eww", "err", err)
+				continue
+			}
+			if err := d.updateDisplay(); err != nil {
+				slog.Error("Failed to restore display", "err", err)
+			}
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// runEww executes an eww command unless eww is known to be down
+func (d *Daemon) runEww(args ...string) error {
+	if d.IsEwwDegraded() {
+		return errEwwDegraded
+	}
+
+	cmd := d.ewwCommand(args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		d.recordEwwFailure(fmt.Errorf("eww %s: %w: %s", args[0], err, output))
+		return err
+	}
+
+	d.watchdog.mu.Lock()
+	d.watchdog.failures = 0
+	d.watchdog.mu.Unlock()
+	return nil
+}
+
+// IsEwwDegraded reports whether eww commands are currently suspended
+func (d *Daemon) IsEwwDegraded() bool {
+	d.watchdog.mu.Lock()
+	defer d.watchdog.mu.Unlock()
+
+	return d.watchdog.degraded
+}
+
+func (d *Daemon) recordEwwFailure(err error) {
+	d.watchdog.mu.Lock()
+	defer d.watchdog.mu.Unlock()
+
+	d.watchdog.failures++
+	d.metrics.countEwwFailure()
+	slog.Error("eww command failed", "failures", d.watchdog.failures, "err", err)
+
+	maxFailures := d.cfg().EwwWatchdog.MaxFailures
+	if maxFailures == 0 || d.watchdog.failures < maxFailures || d.watchdog.degraded {
+		return
+	}
+
+	d.watchdog.degraded = true
+	slog.Warn("eww keeps failing, entering degraded mode", "failures", d.watchdog.failures)
+	probeInterval := time.Duration(d.cfg().EwwWatchdog.ProbeInterval) * time.Second
+	go supervise(d.ctx, "eww probe", func() { d.probeEww(probeInterval) })
+}
+
+// probeEww pings eww until it answers again, then leaves degraded mode and
+// pushes the notifications that piled up in the meantime
+func (d *Daemon) probeEww(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !d.pingEww() {
+				continue
+			}
+
+			d.watchdog.mu.Lock()
+			d.watchdog.degraded = false
+			d.watchdog.failures = 0
+			d.watchdog.mu.Unlock()
+
+			slog.Info("eww is reachable again, leaving degraded mode")
+			if err := d.updateDisplay(); err != nil {
+				slog.Error("Failed to resync display", "err", err)
+			}
+			return
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/cheezecakee/eww-notify-go/internal/history"
+	"github.com/cheezecakee/eww-notify-go/internal/state"
+	"github.com/cheezecakee/eww-notify-go/internal/util/constants"
+	"github.com/cheezecakee/eww-notify-go/internal/util/dbus"
+)
+
+// historyPath is the default persistent history, used when the config does
+// not name one
+var historyPath = constants.GetHistoryPath("")
+
+// SetHistoryPath changes the default persistent history, must be called
+// before the daemon is started
+func SetHistoryPath(path string) {
+	historyPath = path
+}
+
+// historyQueue carries closed notifications from recordClosed, which runs
+// with the state locked, to the history writer so disk writes never hold
+// up the state
+type historyQueue struct {
+	mu      sync.Mutex
+	pending []history.Record
+	wake    chan struct{}
+	// flushing keeps closeHistoryStore from closing the store while the
+	// writer still holds records it took from the queue
+	flushing sync.Mutex
+}
+
+func (q *historyQueue) push(record history.Record) {
+	q.mu.Lock()
+	q.pending = append(q.pending, record)
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *historyQueue) take() []history.Record {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	pending := q.pending
+	q.pending = nil
+	return pending
+}
+
+// openHistoryStore opens the persistent history if enabled, recordClosed
+// queues every closed notification for it
+func (d *Daemon) openHistoryStore() error {
+	cfg := d.cfg().HistoryStore
+	if !cfg.Enabled {
+		return nil
+	}
+
+	path := cfg.Path
+	if path == "" {
+		path = historyPath
+	}
+
+	store, err := history.Open(path, history.Retention{
+		MaxAge:     cfg.MaxAge.Std(),
+		MaxEntries: int(cfg.MaxEntries),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open history store: %w", err)
+	}
+
+	d.historyStore = store
+	d.historyQueue.wake = make(chan struct{}, 1)
+	go supervise(d.ctx, "history writer", d.historyWriterLoop)
+	slog.Debug("Recording history", "path", path)
+	return nil
+}
+
+// historyWriterLoop writes queued records to the persistent history
+func (d *Daemon) historyWriterLoop() {
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-d.historyQueue.wake:
+			d.flushHistory()
+		}
+	}
+}
+
+// flushHistory writes every queued record in one transaction
+func (d *Daemon) flushHistory() {
+	d.historyQueue.flushing.Lock()
+	defer d.historyQueue.flushing.Unlock()
+
+	records := d.historyQueue.take()
+	if len(records) == 0 {
+		return
+	}
+	if err := d.historyStore.Add(records...); err != nil {
+		slog.Error("Failed to record history", "records", len(records), "err", err)
+	}
+}
+
+// closeHistoryStore writes what is still queued and closes the persistent
+// history
+func (d *Daemon) closeHistoryStore() {
+	if d.historyStore == nil {
+		return
+	}
+
+	d.flushHistory()
+	d.historyQueue.flushing.Lock()
+	defer d.historyQueue.flushing.Unlock()
+	if err := d.historyStore.Close(); err != nil {
+		slog.Error("Failed to close history store", "err", err)
+	}
+}
+
+func historyRecord(entry state.HistoryEntry) history.Record {
+	notification := entry.Notification
+	return history.Record{
+		Id:         notification.Id,
+		AppName:    notification.AppName,
+		Summary:    notification.Summary,
+		Body:       notification.Body,
+		Urgency:    dbus.ConfigKeyUrgency(dbus.GetUrgency(notification.Hints)),
+		Category:   notificationCategory(notification),
+		Received:   notification.Timestamp,
+		Closed:     entry.ClosedAt,
+		Reason:     entry.Reason.String(),
+		ReasonCode: entry.Reason.Code(),
+	}
+}
+
+// HistoryQueryJSON searches the persistent history, newest first, in the
+// same shape as HistoryJSON
+func (d *Daemon) HistoryQueryJSON(query history.Query) (string, error) {
+	if d.historyStore == nil {
+		return "", fmt.Errorf("history store is disabled, see [config.history-store]")
+	}
+
+	records := d.historyStore.Query(query)
+	list := make([]map[string]any, 0, len(records))
+	for _, record := range records {
+		list = append(list, map[string]any{
+			"id":          record.Id,
+			"app_name":    record.AppName,
+			"summary":     record.Summary,
+			"body":        record.Body,
+			"urgency":     record.Urgency,
+			"category":    record.Category,
+			"timestamp":   record.Received.Unix(),
+			"closed_at":   record.Closed.Unix(),
+			"reason":      record.Reason,
+			"reason_code": record.ReasonCode,
+		})
+	}
+
+	jsonBytes, err := json.Marshal(list)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal history: %w", err)
+	}
+	return string(jsonBytes), nil
+}
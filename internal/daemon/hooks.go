@@ -0,0 +1,138 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/cheezecakee/eww-notify-go/internal/config"
+	"github.com/cheezecakee/eww-notify-go/internal/state"
+	"github.com/cheezecakee/eww-notify-go/internal/util/dbus"
+)
+
+// hookRunner counts the hook commands still running per event, so a hung
+// script only blocks further runs of the same hook
+type hookRunner struct {
+	mu      sync.Mutex
+	running map[string]uint32
+}
+
+// acquire reserves a slot for a run of the event's hook
+func (h *hookRunner) acquire(event string, limit uint32) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.running == nil {
+		h.running = make(map[string]uint32)
+	}
+	if limit > 0 && h.running[event] >= limit {
+		return false
+	}
+	h.running[event]++
+	return true
+}
+
+func (h *hookRunner) release(event string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.running[event]--
+}
+
+// runHook starts the event's hook with the notification in its environment,
+// without waiting for it
+func (d *Daemon) runHook(event string, notification state.Notification, extra ...string) {
+	var hook config.Hook
+	hooks := d.cfg().Hooks
+	switch event {
+	case "notify":
+		hook = hooks.Notify
+	case "close":
+		hook = hooks.Close
+	case "expire":
+		hook = hooks.Expire
+	case "action":
+		hook = hooks.Action
+	}
+	if len(hook.Command) == 0 {
+		return
+	}
+
+	if !d.hooks.acquire(event, hook.MaxConcurrent) {
+		slog.Warn("Skipping hook, too many runs still going", "event", event, "id", notification.Id, "app", notification.AppName, "running", hook.MaxConcurrent)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if timeout := hook.Timeout.Std(); timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	}
+
+	cmd := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+	cmd.Env = append(os.Environ(), notificationEnv(notification)...)
+	cmd.Env = append(cmd.Env, "END_EVENT="+event)
+	cmd.Env = append(cmd.Env, extra...)
+	// Children holding the output open must not keep Wait from returning
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		d.hooks.release(event)
+		slog.Error("Failed to run hook", "event", event, "id", notification.Id, "err", err)
+		return
+	}
+	go func() {
+		defer d.hooks.release(event)
+		defer cancel()
+
+		if err := cmd.Wait(); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				slog.Warn("Hook timed out and was killed", "event", event, "id", notification.Id, "timeout", hook.Timeout.Std())
+				return
+			}
+			slog.Warn("Hook failed", "event", event, "id", notification.Id, "err", err)
+		}
+	}()
+}
+
+// notificationEnv describes a notification to scripts
+func notificationEnv(notification state.Notification) []string {
+	return []string{
+		fmt.Sprintf("END_ID=%d", notification.Id),
+		"END_APP=" + notification.AppName,
+		"END_APP_NAME=" + notification.AppName,
+		"END_SUMMARY=" + notification.Summary,
+		"END_BODY=" + notification.Body,
+		"END_URGENCY=" + dbus.ConfigKeyUrgency(dbus.GetUrgency(notification.Hints)),
+		"END_CATEGORY=" + notificationCategory(notification),
+		"END_ICON=" + notification.AppIcon,
+	}
+}
+
+// recordClosed receives every notification leaving the state, it runs with
+// the state locked so it must not call back into it
+func (d *Daemon) recordClosed(entry state.HistoryEntry) {
+	if d.historyStore != nil {
+		d.historyQueue.push(historyRecord(entry))
+	}
+
+	if entry.Unseen {
+		return
+	}
+	d.metrics.countClosed(entry.Notification, entry.Reason)
+	d.startClosing(entry.Notification)
+
+	event := "close"
+	if entry.Reason == state.Expired {
+		event = "expire"
+	}
+	// runHook reads the config, wait until the state is unlocked
+	go d.runHook(event, entry.Notification,
+		"END_REASON="+entry.Reason.String(),
+		fmt.Sprintf("END_REASON_CODE=%d", entry.Reason.Code()),
+	)
+}
@@ -0,0 +1,291 @@
+package daemon
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cheezecakee/eww-notify-go/internal/util/constants"
+	"github.com/cheezecakee/eww-notify-go/internal/util/dbus"
+	"github.com/cheezecakee/eww-notify-go/internal/util/xdg"
+)
+
+// imageDir holds the images saved from hints, it must be private to us
+// since an existing file is reused by name
+var imageDir = constants.GetImageDir("")
+
+// SetImageDir changes the image directory, must be called before the
+// daemon is started
+func SetImageDir(dir string) {
+	imageDir = dir
+}
+
+// imagePruneGrace keeps images just written for a notification that is not
+// in the state yet
+const imagePruneGrace = time.Minute
+
+// resolveImage picks the image shown with a notification following the
+// spec's precedence: image-data, image-path, app_icon, icon_data. Raw image
+// hints are written to PNGs eww can show and dropped from hints, so the
+// pixel data doesn't end up in the widget JSON.
+func (d *Daemon) resolveImage(hints map[string]any, appIcon string) string {
+	images := make(map[string]*dbus.ImageData)
+	for _, key := range dbus.ImageDataHintKeys {
+		if imageData, ok := dbus.GetImageDataHint(hints, key); ok {
+			images[key] = imageData
+			delete(hints, key)
+		}
+	}
+
+	if path := saveImageHint(images, "image-data", "image_data"); path != "" {
+		return path
+	}
+
+	for _, key := range []string{"image-path", "image_path"} {
+		if value, ok := dbus.GetStringHint(hints, key); ok {
+			if path, ok := localImagePath(value); ok {
+				return path
+			}
+			// image-path may also name a themed icon
+			if path, ok := d.lookupIcon(value); ok {
+				return path
+			}
+		}
+	}
+
+	if path, ok := localImagePath(appIcon); ok {
+		return path
+	}
+
+	return saveImageHint(images, "icon_data")
+}
+
+// lookupIcon resolves a themed icon name with the configured theme
+func (d *Daemon) lookupIcon(name string) (string, bool) {
+	cfg := d.cfg()
+	return xdg.LookupIcon(name, cfg.IconTheme, int(cfg.IconSize))
+}
+
+// saveImageHint saves the first decodable image among keys
+func saveImageHint(images map[string]*dbus.ImageData, keys ...string) string {
+	for _, key := range keys {
+		imageData, ok := images[key]
+		if !ok {
+			continue
+		}
+		path, err := saveImageData(imageData)
+		if err != nil {
+			slog.Warn("Failed to save image hint", "hint", key, "err", err)
+			continue
+		}
+		return path
+	}
+	return ""
+}
+
+// fileURIPath turns a file:// URI into a filesystem path, anything else is
+// returned unchanged
+func fileURIPath(value string) string {
+	if !strings.HasPrefix(value, "file://") {
+		return value
+	}
+	uri, err := url.Parse(value)
+	if err != nil {
+		return strings.TrimPrefix(value, "file://")
+	}
+	return uri.Path
+}
+
+// localImagePath reports the absolute path of an image given as a path or
+// file:// URI, as long as the file exists
+func localImagePath(value string) (string, bool) {
+	path := fileURIPath(strings.TrimSpace(value))
+	if !filepath.IsAbs(path) {
+		return "", false
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	return filepath.Clean(path), true
+}
+
+// saveImageData encodes imageData as a PNG named after its content, so the
+// same avatar sent again reuses the file
+func saveImageData(imageData *dbus.ImageData) (string, error) {
+	img, err := decodeImageData(imageData)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha1.Sum(imageData.PixelData)
+	name := fmt.Sprintf("%dx%d-%s.png", imageData.Width, imageData.Height, hex.EncodeToString(sum[:]))
+	return writeImageFile(name, func(w io.Writer) error {
+		if err := png.Encode(w, img); err != nil {
+			return fmt.Errorf("failed to encode image: %w", err)
+		}
+		return nil
+	})
+}
+
+// saveEncodedImage stores an image file received as bytes, such as a PNG,
+// under a name derived from its content
+func saveEncodedImage(data []byte) (string, error) {
+	sum := sha1.Sum(data)
+	name := hex.EncodeToString(sum[:])
+	switch contentType := http.DetectContentType(data); {
+	case contentType == "image/png":
+		name += ".png"
+	case contentType == "image/jpeg":
+		name += ".jpg"
+	case bytes.Contains(data, []byte("<svg")):
+		name += ".svg"
+	case strings.HasPrefix(contentType, "image/"):
+		name += "." + strings.TrimPrefix(contentType, "image/")
+	default:
+		return "", fmt.Errorf("unsupported image type %s", contentType)
+	}
+
+	return writeImageFile(name, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// ensureImageDir creates the image directory and makes sure nobody else
+// can plant files in it
+func ensureImageDir() error {
+	if err := os.MkdirAll(imageDir, 0o700); err != nil {
+		return fmt.Errorf("failed to create image directory: %w", err)
+	}
+
+	info, err := os.Lstat(imageDir)
+	if err != nil {
+		return fmt.Errorf("failed to check image directory: %w", err)
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !info.IsDir() || !ok || int(stat.Uid) != os.Getuid() || info.Mode().Perm() != 0o700 {
+		return fmt.Errorf("image directory %s must be a directory owned by us with mode 0700", imageDir)
+	}
+	return nil
+}
+
+// writeImageFile saves an image into the image directory unless a file of
+// that name exists already
+func writeImageFile(name string, write func(io.Writer) error) (string, error) {
+	if err := ensureImageDir(); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(imageDir, name)
+	if _, err := os.Stat(path); err == nil {
+		// Keep pruneImages from taking it before the notification is added
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		return path, nil
+	}
+
+	// Write under a temporary name so eww never reads a partial file
+	file, err := os.CreateTemp(imageDir, "*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create image file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	if err := write(file); err != nil {
+		file.Close()
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write image: %w", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to save image: %w", err)
+	}
+
+	return path, nil
+}
+
+// pruneImages deletes the saved images no active, closing or history
+// notification shows anymore
+func (d *Daemon) pruneImages() {
+	entries, err := os.ReadDir(imageDir)
+	if err != nil {
+		return
+	}
+
+	inUse := make(map[string]bool)
+	for _, notification := range d.withClosing(d.state.GetNotifications()) {
+		inUse[notification.Image] = true
+	}
+	for _, entry := range d.state.GetHistory() {
+		inUse[entry.Notification.Image] = true
+	}
+
+	cutoff := time.Now().Add(-imagePruneGrace)
+	for _, entry := range entries {
+		path := filepath.Join(imageDir, entry.Name())
+		if inUse[path] || !entry.Type().IsRegular() {
+			continue
+		}
+		if info, err := entry.Info(); err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			slog.Debug("Failed to remove image", "path", path, "err", err)
+		}
+	}
+}
+
+// decodeImageData converts the spec's raw RGB(A) rows into an image
+func decodeImageData(imageData *dbus.ImageData) (image.Image, error) {
+	width, height := int(imageData.Width), int(imageData.Height)
+	stride, channels := int(imageData.Stride), int(imageData.Channels)
+
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid size %dx%d", width, height)
+	}
+	if imageData.BitsPerSample != 8 {
+		return nil, fmt.Errorf("unsupported bits per sample %d", imageData.BitsPerSample)
+	}
+	if channels != 3 && channels != 4 {
+		return nil, fmt.Errorf("unsupported channel count %d", channels)
+	}
+	if imageData.HasAlpha != (channels == 4) {
+		return nil, fmt.Errorf("alpha flag does not match %d channels", channels)
+	}
+	if stride < width*channels {
+		return nil, fmt.Errorf("row stride %d too small for width %d", stride, width)
+	}
+	if len(imageData.PixelData) < stride*(height-1)+width*channels {
+		return nil, fmt.Errorf("pixel data too short for %dx%d", width, height)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		row := imageData.PixelData[y*stride:]
+		for x := range width {
+			pixel := row[x*channels:]
+			alpha := uint8(255)
+			if channels == 4 {
+				alpha = pixel[3]
+			}
+			img.SetNRGBA(x, y, color.NRGBA{R: pixel[0], G: pixel[1], B: pixel[2], A: alpha})
+		}
+	}
+	return img, nil
+}
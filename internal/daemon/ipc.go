@@ -3,22 +3,78 @@ package daemon
 import (
 	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/cheezecakee/eww-notify-go/internal/history"
 	"github.com/cheezecakee/eww-notify-go/internal/state"
 	"github.com/cheezecakee/eww-notify-go/internal/util/constants"
+	"github.com/cheezecakee/eww-notify-go/internal/util/systemd"
 )
 
+// handshakeTimeout bounds how long a client waits for the hello reply
+const handshakeTimeout = 2 * time.Second
+
+const (
+	// ipcIdleTimeout closes connections that neither send a command nor
+	// accept a reply for this long
+	ipcIdleTimeout = 30 * time.Second
+
+	// ipcMaxLineSize bounds a single command or request
+	ipcMaxLineSize = 64 * 1024
+
+	// ipcMaxConnections bounds concurrently served connections, including
+	// subscribers
+	ipcMaxConnections = 32
+)
+
+// socketPath is the IPC socket used by both the server and SendIPCCommand
+var socketPath = constants.GetSocketPath("")
+
+// SetSocketPath changes the IPC socket path, must be called before the
+// server is started or any command is sent
+func SetSocketPath(path string) {
+	socketPath = path
+}
+
+// GetSocketPath returns the IPC socket path currently in use
+func GetSocketPath() string {
+	return socketPath
+}
+
+// ipcRequest is a command sent by clients speaking the JSON protocol, one
+// request per line
+type ipcRequest struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// ipcResponse acknowledges every ipcRequest, Payload holds whatever the
+// command printed
+type ipcResponse struct {
+	Ok      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+	Payload string `json:"payload,omitempty"`
+}
+
 // IPCServer handles Unix socket communication
 type IPCServer struct {
-	daemon   *Daemon
-	listener net.Listener
-	ctx      context.Context
-	cancel   context.CancelFunc
+	daemon    *Daemon
+	listener  net.Listener
+	activated bool // Listener owned by systemd, the socket file must stay
+	slots     chan struct{}
+	ctx       context.Context
+	cancel    context.CancelFunc
 }
 
 // NewIPCServer creates a new IPC server
@@ -26,28 +82,58 @@ func NewIPCServer(daemon *Daemon) *IPCServer {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &IPCServer{
 		daemon: daemon,
+		slots:  make(chan struct{}, ipcMaxConnections),
 		ctx:    ctx,
 		cancel: cancel,
 	}
 }
 
-// Start starts the IPC server
+// Start starts the IPC server, using the socket passed by systemd socket
+// activation when there is one
 func (s *IPCServer) Start() error {
+	listener, err := systemd.Listener()
+	if err != nil {
+		return err
+	}
+	if listener != nil {
+		if addr, ok := listener.Addr().(*net.UnixAddr); ok && addr.Name != "" {
+			socketPath = addr.Name
+		}
+		slog.Info("Using IPC socket passed by systemd", "path", socketPath)
+		s.listener = listener
+		s.activated = true
+		go supervise(s.ctx, "IPC accept loop", s.acceptLoop)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0o700); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+
 	// Remove existing socket file if it exists
-	if err := os.RemoveAll(constants.IPCSocketPath); err != nil {
+	if err := os.RemoveAll(socketPath); err != nil {
 		return fmt.Errorf("failed to remove existing socket: %w", err)
 	}
 
-	// Create Unix socket listener
-	listener, err := net.Listen("unix", constants.IPCSocketPath)
+	// Create Unix socket listener, the umask keeps the socket private from
+	// the start instead of leaving a window until the chmod below
+	oldMask := syscall.Umask(0o177)
+	listener, err = net.Listen("unix", socketPath)
+	syscall.Umask(oldMask)
 	if err != nil {
 		return fmt.Errorf("failed to create Unix socket listener: %w", err)
 	}
 
+	// Only the daemon's user may talk to it
+	if err := os.Chmod(socketPath, 0o600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+
 	s.listener = listener
 
 	// Start accepting connections
-	go s.acceptLoop()
+	go supervise(s.ctx, "IPC accept loop", s.acceptLoop)
 
 	return nil
 }
@@ -62,8 +148,11 @@ func (s *IPCServer) Stop() error {
 		}
 	}
 
-	// Clean up socket file
-	return os.RemoveAll(constants.IPCSocketPath)
+	// Clean up socket file, systemd keeps its socket for the next start
+	if s.activated {
+		return nil
+	}
+	return os.RemoveAll(socketPath)
 }
 
 // acceptLoop accepts and handles IPC connections
@@ -80,13 +169,25 @@ func (s *IPCServer) acceptLoop() {
 				case <-s.ctx.Done():
 					return
 				default:
-					fmt.Printf("Failed to accept IPC connection: %v\n", err)
+					slog.Error("Failed to accept IPC connection", "err", err)
 					continue
 				}
 			}
 
-			// Handle connection in goroutine
-			go s.handleConnection(conn)
+			select {
+			case s.slots <- struct{}{}:
+			default:
+				slog.Warn("Rejected IPC connection, too many connections open", "max", ipcMaxConnections)
+				conn.Close()
+				continue
+			}
+
+			// Handle connection in goroutine, a panicking command only
+			// drops its own connection
+			go func() {
+				defer func() { <-s.slots }()
+				runRecovered("IPC connection", func() { s.handleConnection(conn) })
+			}()
 		}
 	}
 }
@@ -95,43 +196,288 @@ func (s *IPCServer) acceptLoop() {
 func (s *IPCServer) handleConnection(conn net.Conn) {
 	defer conn.Close()
 
+	if err := checkPeerCredentials(conn); err != nil {
+		slog.Warn("Rejected IPC connection", "err", err)
+		return
+	}
+
 	scanner := bufio.NewScanner(conn)
-	for scanner.Scan() {
+	scanner.Buffer(make([]byte, 0, 4096), ipcMaxLineSize)
+	for {
+		conn.SetReadDeadline(time.Now().Add(ipcIdleTimeout))
+		if !scanner.Scan() {
+			break
+		}
+		conn.SetWriteDeadline(time.Now().Add(ipcIdleTimeout))
+
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
 			continue
 		}
 
-		if err := s.handleCommand(line); err != nil {
-			fmt.Printf("Failed to handle IPC command '%s': %v\n", line, err)
+		// Clients announce their protocol version before sending commands,
+		// plain commands without a handshake are still accepted for scripts
+		if fields := strings.Fields(line); fields[0] == "hello" {
+			if err := s.handleHello(conn, fields[1:]); err != nil {
+				slog.Warn("IPC handshake failed", "err", err)
+				return
+			}
+			continue
 		}
+
+		// JSON requests are acknowledged, plain text commands are fire and
+		// forget
+		if strings.HasPrefix(line, "{") {
+			if err := s.handleRequest(conn, line); err != nil {
+				slog.Warn("Failed to answer IPC request", "request", line, "err", err)
+				return
+			}
+			continue
+		}
+
+		if err := s.handleCommand(conn, line); err != nil {
+			slog.Warn("Failed to handle IPC command", "command", line, "err", err)
+		}
+	}
+
+	switch err := scanner.Err(); {
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		slog.Debug("Closing idle IPC connection")
+	case errors.Is(err, bufio.ErrTooLong):
+		slog.Warn("Closing IPC connection, command too long", "max_bytes", ipcMaxLineSize)
+	case err != nil:
+		slog.Warn("Error reading from IPC connection", "err", err)
+	}
+}
+
+// handleHello answers a client's version announcement, refusing clients
+// that speak a different protocol version
+func (s *IPCServer) handleHello(conn net.Conn, args []string) error {
+	if len(args) < 1 {
+		fmt.Fprintf(conn, "error missing protocol version\n")
+		return fmt.Errorf("missing protocol version")
 	}
 
-	if err := scanner.Err(); err != nil {
-		fmt.Printf("Error reading from IPC connection: %v\n", err)
+	version, err := strconv.Atoi(args[0])
+	if err != nil || version != constants.IPCProtocolVersion {
+		fmt.Fprintf(conn, "error protocol %d %s\n", constants.IPCProtocolVersion, constants.AppVersion)
+		return fmt.Errorf("client protocol version %s does not match %d", args[0], constants.IPCProtocolVersion)
 	}
+
+	_, err = fmt.Fprintf(conn, "hello %d %s\n", constants.IPCProtocolVersion, constants.AppVersion)
+	return err
 }
 
-// handleCommand processes a single IPC command
-func (s *IPCServer) handleCommand(command string) error {
+// handleRequest runs a JSON request and writes its response, the returned
+// error only reports a broken connection
+func (s *IPCServer) handleRequest(conn net.Conn, line string) error {
+	encoder := json.NewEncoder(conn)
+
+	var request ipcRequest
+	if err := json.Unmarshal([]byte(line), &request); err != nil {
+		return encoder.Encode(ipcResponse{Error: fmt.Sprintf("invalid request: %v", err)})
+	}
+	if request.Command == "" {
+		return encoder.Encode(ipcResponse{Error: "empty command"})
+	}
+
+	// Streams are acknowledged up front, the events follow as JSON lines
+	if request.Command == "subscribe" {
+		if err := encoder.Encode(ipcResponse{Ok: true}); err != nil {
+			return err
+		}
+		return s.runCommand(conn, request.Command, request.Args)
+	}
+
+	var output strings.Builder
+	response := ipcResponse{Ok: true}
+	if err := s.runCommand(&output, request.Command, request.Args); err != nil {
+		slog.Warn("Failed to handle IPC command", "command", request.Command, "err", err)
+		response = ipcResponse{Error: err.Error()}
+	}
+	response.Payload = output.String()
+
+	return encoder.Encode(response)
+}
+
+// handleCommand processes a single plain text IPC command, commands
+// producing output write it to w
+func (s *IPCServer) handleCommand(w io.Writer, command string) error {
 	parts := strings.Fields(command)
 	if len(parts) == 0 {
 		return fmt.Errorf("empty command")
 	}
 
-	cmd := parts[0]
-	args := parts[1:]
+	return s.runCommand(w, parts[0], parts[1:])
+}
 
+// runCommand executes cmd with its arguments
+func (s *IPCServer) runCommand(w io.Writer, cmd string, args []string) error {
 	switch cmd {
 	case "kill":
 		return s.handleKillCommand()
 
+	case "reload":
+		return s.daemon.Reload()
+
+	case "subscribe":
+		return s.handleSubscribeCommand(w)
+
+	case "status":
+		_, err := io.WriteString(w, s.daemon.Status())
+		return err
+
 	case "action":
 		return s.handleActionCommand(args)
 
+	case "default-action":
+		if len(args) != 1 {
+			return fmt.Errorf("default-action command requires a notification ID")
+		}
+		return s.handleActionCommand(args)
+
 	case "close":
 		return s.handleCloseCommand(args)
 
+	case "close-from":
+		if len(args) < 1 {
+			return fmt.Errorf("close-from command requires an app name or D-Bus sender")
+		}
+		_, err := s.daemon.CloseFrom(strings.Join(args, " "))
+		return err
+
+	case "reply":
+		return s.handleReplyCommand(args)
+
+	case "cycle":
+		return s.handleCycleCommand(args)
+
+	case "expand", "collapse":
+		if len(args) < 1 {
+			return fmt.Errorf("%s command requires an app name", cmd)
+		}
+		// App names may contain spaces
+		return s.daemon.SetGroupExpanded(strings.Join(args, " "), cmd == "expand")
+
+	case "mute", "unmute":
+		if len(args) < 1 {
+			return fmt.Errorf("%s command requires an app name", cmd)
+		}
+		// Notifications are muted by their canonical app name
+		appName := s.daemon.cfg().NormalizeAppName(strings.Join(args, " "))
+		s.daemon.state.SetMuted(appName, cmd == "mute")
+		return nil
+
+	case "muted":
+		for _, app := range s.daemon.state.GetMutedApps() {
+			if _, err := fmt.Fprintln(w, app); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "list":
+		list, err := s.daemon.ListJSON()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, list)
+		return err
+
+	case "pause":
+		return s.daemon.PauseTimeouts()
+
+	case "pause-timeout", "resume-timeout":
+		if len(args) < 1 {
+			return fmt.Errorf("%s command requires notification ID", cmd)
+		}
+		id, err := s.parseNotificationId(args[0])
+		if err != nil {
+			return err
+		}
+		if cmd == "pause-timeout" {
+			return s.daemon.PauseTimeout(id)
+		}
+		return s.daemon.ResumeTimeout(id)
+
+	case "resume":
+		return s.daemon.ResumeTimeouts()
+
+	case "pin", "unpin":
+		if len(args) < 1 {
+			return fmt.Errorf("%s command requires notification ID", cmd)
+		}
+		id, err := s.parseNotificationId(args[0])
+		if err != nil {
+			return err
+		}
+		return s.daemon.SetPinned(id, cmd == "pin")
+
+	case "dnd":
+		return s.handleDndCommand(w, args)
+
+	case "log-level":
+		return s.handleLogLevelCommand(w, args)
+
+	case "stats":
+		if len(args) == 1 && args[0] == "prometheus" {
+			return s.daemon.WritePrometheus(w)
+		}
+		stats, err := s.daemon.StatsJSON()
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, stats+"\n")
+		return err
+
+	case "history":
+		return s.handleHistoryCommand(w, args)
+
+	case "center":
+		return s.handleCenterCommand(args)
+
+	case "count":
+		return s.handleCountCommand(w, args)
+
+	case "extend":
+		return s.handleExtendCommand(args)
+
+	case "set":
+		return s.handleSetCommand(args)
+
+	case "get":
+		// A notification ID selects the notification instead of settings
+		if len(args) == 1 && isNotificationId(args[0]) {
+			id, err := s.parseNotificationId(args[0])
+			if err != nil {
+				return err
+			}
+			notification, err := s.daemon.NotificationJSON(id)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(w, notification)
+			return err
+		}
+		values, err := s.daemon.GetConfigValues(args)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, values)
+		return err
+
+	case "select-next":
+		return s.daemon.SelectNext()
+
+	case "select-prev":
+		return s.daemon.SelectPrev()
+
+	case "activate-selected":
+		return s.daemon.ActivateSelected()
+
+	case "dismiss-selected":
+		return s.daemon.DismissSelected()
+
 	default:
 		return fmt.Errorf("unknown command: %s", cmd)
 	}
@@ -139,35 +485,52 @@ func (s *IPCServer) handleCommand(command string) error {
 
 // handleKillCommand handles the kill command (shutdown daemon)
 func (s *IPCServer) handleKillCommand() error {
-	fmt.Println("Received kill command, shutting down daemon...")
+	slog.Info("Received kill command, shutting down daemon")
 
 	// Stop the daemon (this should be handled by the main process)
 	go func() {
 		if err := s.daemon.Stop(); err != nil {
-			fmt.Printf("Error stopping daemon: %v\n", err)
+			slog.Error("Failed to stop daemon", "err", err)
 		}
+		// Exiting here skips main's cleanup
+		RemovePidFile()
 		os.Exit(0)
 	}()
 
 	return nil
 }
 
-// handleActionCommand handles action invocation
+// handleActionCommand handles action invocation, without a key or with
+// the "default" key it triggers the notification's default action, falling
+// back to its first one
 func (s *IPCServer) handleActionCommand(args []string) error {
+	if len(args) == 1 {
+		if args[0] == "latest" {
+			return s.daemon.ActivateLatest()
+		}
+		id, err := s.parseNotificationId(args[0])
+		if err != nil {
+			return err
+		}
+		return s.daemon.Activate(id)
+	}
 	if len(args) < 2 {
 		return fmt.Errorf("action command requires notification ID and action key")
 	}
 
 	// Parse notification ID
-	id, err := strconv.ParseUint(args[0], 10, 32)
+	id, err := s.parseNotificationId(args[0])
 	if err != nil {
-		return fmt.Errorf("invalid notification ID: %w", err)
+		return err
 	}
 
 	actionKey := args[1]
+	if actionKey == DefaultActionKey {
+		return s.daemon.Activate(id)
+	}
 
 	// Invoke action
-	if err := s.daemon.InvokeAction(uint32(id), actionKey); err != nil {
+	if err := s.daemon.InvokeAction(id, actionKey); err != nil {
 		return fmt.Errorf("failed to invoke action: %w", err)
 	}
 
@@ -181,35 +544,444 @@ func (s *IPCServer) handleCloseCommand(args []string) error {
 	}
 
 	// Parse notification ID
-	id, err := strconv.ParseUint(args[0], 10, 32)
+	id, err := s.parseNotificationId(args[0])
 	if err != nil {
-		return fmt.Errorf("invalid notification ID: %w", err)
+		return err
 	}
 
 	// Remove notification
-	if err := s.daemon.RemoveNotification(uint32(id)); err != nil {
+	if err := s.daemon.RemoveNotification(id); err != nil {
 		return fmt.Errorf("failed to remove notification: %w", err)
 	}
 
 	// Emit closed signal
-	if err := s.daemon.dbusServer.EmitNotificationClosed(uint32(id), state.Dismiss); err != nil {
+	if err := s.daemon.dbusServer.EmitNotificationClosed(id, state.Dismiss); err != nil {
 		return fmt.Errorf("failed to emit notification closed signal: %w", err)
 	}
 
 	return nil
 }
 
-// SendIPCCommand sends a command to the IPC socket (utility function for CLI)
+// handleReplyCommand sends the inline reply typed into a notification
+func (s *IPCServer) handleReplyCommand(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("reply command requires notification ID and text")
+	}
+
+	id, err := s.parseNotificationId(args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := s.daemon.Reply(id, strings.Join(args[1:], " ")); err != nil {
+		return fmt.Errorf("failed to send reply: %w", err)
+	}
+
+	return nil
+}
+
+// parseNotificationId accepts a numeric ID or "latest" for the most
+// recent notification
+func (s *IPCServer) parseNotificationId(arg string) (uint32, error) {
+	if arg == "latest" {
+		latest, ok := s.daemon.state.GetLatest()
+		if !ok {
+			return 0, fmt.Errorf("no active notifications")
+		}
+		return latest.Id, nil
+	}
+
+	id, err := strconv.ParseUint(arg, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid notification ID: %w", err)
+	}
+	return uint32(id), nil
+}
+
+// isNotificationId reports whether arg names a notification rather than
+// a setting
+func isNotificationId(arg string) bool {
+	if arg == "latest" {
+		return true
+	}
+	_, err := strconv.ParseUint(arg, 10, 32)
+	return err == nil
+}
+
+// handleCycleCommand cycles through a stacked app's notifications
+func (s *IPCServer) handleCycleCommand(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("cycle command requires an app name")
+	}
+
+	// App names may contain spaces
+	appName := strings.Join(args, " ")
+
+	if err := s.daemon.CycleStack(appName); err != nil {
+		return fmt.Errorf("failed to cycle stack: %w", err)
+	}
+
+	return nil
+}
+
+// handleSubscribeCommand streams events as JSON lines until the client
+// goes away or the server stops
+func (s *IPCServer) handleSubscribeCommand(w io.Writer) error {
+	events, unsubscribe := s.daemon.events.subscribe()
+	defer unsubscribe()
+
+	encoder := json.NewEncoder(w)
+	conn, _ := w.(net.Conn)
+	for {
+		select {
+		case event := <-events:
+			// Subscribers only have to keep up, not stay inside the
+			// deadline of the subscribe command itself
+			if conn != nil {
+				conn.SetWriteDeadline(time.Now().Add(ipcIdleTimeout))
+			}
+			if err := encoder.Encode(event); err != nil {
+				// Client disconnected
+				return nil
+			}
+		case <-s.ctx.Done():
+			return nil
+		}
+	}
+}
+
+// handleDndCommand switches Do-Not-Disturb or reports its state
+func (s *IPCServer) handleDndCommand(w io.Writer, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("dnd command requires on, off, toggle or status")
+	}
+
+	switch args[0] {
+	case "on":
+		return s.daemon.SetDnd(true)
+	case "off":
+		return s.daemon.SetDnd(false)
+	case "toggle":
+		return s.daemon.ToggleDnd()
+	case "status":
+		_, err := io.WriteString(w, s.daemon.DndStatus())
+		return err
+	default:
+		return fmt.Errorf("unknown dnd operation: %s", args[0])
+	}
+}
+
+// handleLogLevelCommand prints the log level, or changes it until the
+// daemon exits
+func (s *IPCServer) handleLogLevelCommand(w io.Writer, args []string) error {
+	switch len(args) {
+	case 0:
+		_, err := io.WriteString(w, s.daemon.LogLevel()+"\n")
+		return err
+	case 1:
+		return s.daemon.SetLogLevel(args[0])
+	default:
+		return fmt.Errorf("log-level command takes at most one level")
+	}
+}
+
+// handleHistoryCommand lists, clears or pops the notification history, or
+// searches the persistent history store
+func (s *IPCServer) handleHistoryCommand(w io.Writer, args []string) error {
+	if len(args) > 0 && args[0] == "query" {
+		return s.handleHistoryQuery(w, args[1:])
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("history command requires list, clear, pop or query")
+	}
+
+	switch args[0] {
+	case "list":
+		history, err := s.daemon.HistoryJSON()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, history)
+		return err
+	case "clear":
+		s.daemon.state.ClearHistory()
+		return nil
+	case "pop":
+		return s.daemon.PopHistory()
+	default:
+		return fmt.Errorf("unknown history operation: %s", args[0])
+	}
+}
+
+// handleHistoryQuery searches the history store:
+// history query [--app NAME] [--since TIME] [--until TIME] [--text WORDS] [--limit N]
+func (s *IPCServer) handleHistoryQuery(w io.Writer, args []string) error {
+	options := parseOptions(args)
+	query := history.Query{AppName: options["app"], Text: options["text"]}
+
+	var err error
+	if value, ok := options["since"]; ok {
+		if query.Since, err = parseHistoryTime(value); err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
+	}
+	if value, ok := options["until"]; ok {
+		if query.Until, err = parseHistoryTime(value); err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+	}
+	if value, ok := options["limit"]; ok {
+		limit, err := strconv.ParseUint(value, 10, 31)
+		if err != nil {
+			return fmt.Errorf("invalid --limit: %w", err)
+		}
+		query.Limit = int(limit)
+	}
+
+	records, err := s.daemon.HistoryQueryJSON(query)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, records)
+	return err
+}
+
+// parseHistoryTime reads an RFC 3339 time, a date, unix seconds or a
+// duration meaning that long ago, such as 2h
+func parseHistoryTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	if ago, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-ago), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a time, date, unix timestamp or duration", value)
+}
+
+// handleCenterCommand opens, closes or toggles the notification center
+func (s *IPCServer) handleCenterCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("center command requires toggle, open or close")
+	}
+
+	switch args[0] {
+	case "toggle":
+		return s.daemon.ToggleCenter()
+	case "open":
+		return s.daemon.SetCenter(true)
+	case "close":
+		return s.daemon.SetCenter(false)
+	default:
+		return fmt.Errorf("unknown center operation: %s", args[0])
+	}
+}
+
+// handleCountCommand writes the number of matching notifications, filters
+// are given as --urgency <level> and --app <name>
+func (s *IPCServer) handleCountCommand(w io.Writer, args []string) error {
+	options := parseOptions(args)
+
+	switch urgency := options["urgency"]; urgency {
+	case "", "low", "normal", "critical":
+	default:
+		return fmt.Errorf("invalid urgency %q", urgency)
+	}
+
+	_, err := fmt.Fprintln(w, s.daemon.Count(options["urgency"], options["app"]))
+	return err
+}
+
+// parseOptions turns "--key value words" arguments into a map, values run
+// until the next --key so app names may contain spaces
+func parseOptions(args []string) map[string]string {
+	options := make(map[string]string)
+	key := ""
+	for _, arg := range args {
+		if name, ok := strings.CutPrefix(arg, "--"); ok {
+			key = name
+			options[key] = ""
+			continue
+		}
+		if key == "" {
+			continue
+		}
+		if options[key] != "" {
+			options[key] += " "
+		}
+		options[key] += arg
+	}
+	return options
+}
+
+// handleExtendCommand pushes back a notification's expiry, the duration is
+// either a number of seconds or a Go duration such as 1m30s
+func (s *IPCServer) handleExtendCommand(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("extend command requires notification ID and duration")
+	}
+
+	id, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid notification ID: %w", err)
+	}
+
+	duration, err := parseDuration(args[1])
+	if err != nil {
+		return err
+	}
+
+	if err := s.daemon.ExtendTimeout(uint32(id), duration); err != nil {
+		return fmt.Errorf("failed to extend timeout: %w", err)
+	}
+
+	return nil
+}
+
+// parseDuration accepts plain seconds as well as Go duration strings
+func parseDuration(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return duration, nil
+}
+
+// handleSetCommand changes a setting for the rest of the session
+func (s *IPCServer) handleSetCommand(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("set command requires a key and a value")
+	}
+
+	if err := s.daemon.SetConfigValue(args[0], args[1]); err != nil {
+		return fmt.Errorf("failed to set %s: %w", args[0], err)
+	}
+
+	return nil
+}
+
+// SendIPCCommand sends a command to the IPC socket and waits for the daemon
+// to acknowledge it (utility function for CLI)
 func SendIPCCommand(command string) error {
-	conn, err := net.Dial("unix", constants.IPCSocketPath)
+	_, err := QueryIPCCommand(command)
+	return err
+}
+
+// QueryIPCCommand sends a command and returns the payload of the daemon's
+// response, a failed command is returned as an error
+func QueryIPCCommand(command string) (string, error) {
+	conn, err := dialIPC()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	response, err := roundTrip(conn, bufio.NewReader(conn), command)
+	if err != nil {
+		return "", err
+	}
+
+	return response.Payload, nil
+}
+
+// StreamIPCCommand sends a command and copies everything the daemon writes
+// back after its acknowledgment to out until the connection closes
+func StreamIPCCommand(command string, out io.Writer) error {
+	conn, err := dialIPC()
 	if err != nil {
-		return fmt.Errorf("daemon is not running, run end first")
+		return err
 	}
 	defer conn.Close()
 
-	_, err = conn.Write([]byte(command + "\n"))
+	reader := bufio.NewReader(conn)
+	if _, err := roundTrip(conn, reader, command); err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, reader); err != nil {
+		return fmt.Errorf("connection to daemon lost: %w", err)
+	}
+	return nil
+}
+
+// dialIPC connects to the daemon and performs the handshake
+func dialIPC() (net.Conn, error) {
+	conn, err := net.Dial("unix", socketPath)
 	if err != nil {
-		return fmt.Errorf("failed to send command: %w", err)
+		return nil, fmt.Errorf("daemon is not running, run end first")
+	}
+
+	if err := handshake(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// roundTrip sends command as a JSON request and reads its response
+func roundTrip(conn net.Conn, reader *bufio.Reader, command string) (ipcResponse, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return ipcResponse{}, fmt.Errorf("empty command")
+	}
+
+	if err := json.NewEncoder(conn).Encode(ipcRequest{Command: fields[0], Args: fields[1:]}); err != nil {
+		return ipcResponse{}, fmt.Errorf("failed to send command: %w", err)
+	}
+
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return ipcResponse{}, fmt.Errorf("daemon closed the connection without answering: %w", err)
+	}
+
+	var response ipcResponse
+	if err := json.Unmarshal(line, &response); err != nil {
+		return ipcResponse{}, fmt.Errorf("invalid response from daemon: %w", err)
+	}
+	if !response.Ok {
+		return response, fmt.Errorf("%s", response.Error)
+	}
+
+	return response, nil
+}
+
+// handshake announces the client's protocol version and waits for the
+// daemon to accept it
+func handshake(conn net.Conn) error {
+	if _, err := fmt.Fprintf(conn, "hello %d\n", constants.IPCProtocolVersion); err != nil {
+		return fmt.Errorf("failed to send handshake: %w", err)
+	}
+
+	// Daemons predating the handshake never answer
+	conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("daemon did not answer handshake, it may be an older version: %w", err)
+	}
+
+	fields := strings.Fields(reply)
+	if len(fields) >= 3 && fields[0] == "error" && fields[1] == "protocol" {
+		daemonVersion := "unknown"
+		if len(fields) >= 4 {
+			daemonVersion = fields[3]
+		}
+		return fmt.Errorf("protocol mismatch: daemon %s speaks IPC protocol %s, this client speaks %d (version %s), restart the daemon",
+			daemonVersion, fields[2], constants.IPCProtocolVersion, constants.AppVersion)
+	}
+	if len(fields) < 2 || fields[0] != "hello" {
+		return fmt.Errorf("unexpected handshake reply: %q", strings.TrimSpace(reply))
 	}
 
 	return nil
@@ -0,0 +1,126 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// InstanceLock keeps a second daemon from starting on the same socket, the
+// kernel drops the flock when the process dies so a crash never leaves a
+// lock behind
+type InstanceLock struct {
+	file *os.File
+}
+
+// ErrAlreadyRunning is returned by AcquireInstanceLock while another daemon
+// holds the lock
+var ErrAlreadyRunning = errors.New("daemon already running")
+
+// lockPath returns the lock file belonging to the current socket
+func lockPath() string {
+	return socketPath + ".lock"
+}
+
+// PidFilePath returns the PID file of a daemon running in the background,
+// next to the current socket
+func PidFilePath() string {
+	return strings.TrimSuffix(socketPath, ".sock") + ".pid"
+}
+
+// WritePidFile records our PID for scripts managing a background daemon
+func WritePidFile() error {
+	path := PidFilePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create PID file directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to write PID file: %w", err)
+	}
+	return nil
+}
+
+// RemovePidFile removes the PID file if it is still ours
+func RemovePidFile() {
+	path := PidFilePath()
+	if ReadPidFile() == os.Getpid() {
+		os.Remove(path)
+	}
+}
+
+// ReadPidFile returns the PID in the PID file, 0 if there is none
+func ReadPidFile() int {
+	data, err := os.ReadFile(PidFilePath())
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
+
+// AcquireInstanceLock takes the lock for the current socket and records our
+// PID in it
+func AcquireInstanceLock() (*InstanceLock, error) {
+	path := lockPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		pid := readLockPid(file)
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("%w (pid %d)", ErrAlreadyRunning, pid)
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	// Whoever wrote the old PID is gone, so is their socket
+	if pid := readLockPid(file); pid != 0 && pid != os.Getpid() {
+		slog.Info("Removing stale lock", "pid", pid)
+		if err := os.RemoveAll(socketPath); err != nil {
+			slog.Warn("Failed to remove stale socket", "err", err)
+		}
+	}
+
+	if err := writeLockPid(file, os.Getpid()); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write lock file: %w", err)
+	}
+
+	return &InstanceLock{file: file}, nil
+}
+
+// Release clears our PID and drops the lock, the file itself stays so a
+// concurrently starting daemon never locks an unlinked copy
+func (l *InstanceLock) Release() error {
+	l.file.Truncate(0)
+	return l.file.Close()
+}
+
+// readLockPid returns the PID stored in the lock file, 0 if there is none
+func readLockPid(file *os.File) int {
+	data := make([]byte, 32)
+	n, _ := file.ReadAt(data, 0)
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data[:n])))
+	return pid
+}
+
+// writeLockPid replaces the contents of the lock file with pid
+func writeLockPid(file *os.File, pid int) error {
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := file.WriteAt([]byte(strconv.Itoa(pid)+"\n"), 0)
+	return err
+}
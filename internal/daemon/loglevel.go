@@ -0,0 +1,37 @@
+package daemon
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/cheezecakee/eww-notify-go/internal/config"
+	"github.com/cheezecakee/eww-notify-go/internal/util/logging"
+)
+
+// applyLogLevel follows the config's log-level, unless -log-level or the
+// log-level command chose one
+func applyLogLevel(cfg config.Config) {
+	level, err := logging.ParseLevel(string(cfg.LogLevel))
+	if err != nil {
+		slog.Warn("Ignoring log-level", "err", err)
+		return
+	}
+	logging.SetConfigLevel(level)
+}
+
+// SetLogLevel changes the log level until the daemon exits, config reloads
+// keep it
+func (d *Daemon) SetLogLevel(name string) error {
+	level, err := logging.ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	logging.SetLevel(level)
+	slog.Info("Log level changed", "level", d.LogLevel())
+	return nil
+}
+
+// LogLevel returns the current log level as accepted by SetLogLevel
+func (d *Daemon) LogLevel() string {
+	return strings.ToLower(logging.Level().String())
+}
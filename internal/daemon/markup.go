@@ -0,0 +1,264 @@
+package daemon
+
+import (
+	"fmt"
+	"html"
+	"log/slog"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cheezecakee/eww-notify-go/internal/config"
+	"github.com/cheezecakee/eww-notify-go/internal/state"
+)
+
+// markupTags are the body tags kept when translating, everything else the
+// spec allows (img) or clients invent is dropped
+var markupTags = map[string]bool{"b": true, "i": true, "u": true, "a": true}
+
+// formatBody turns the body's markup into what the widget expects: plain
+// text, Pango markup eww labels render with :markup, or the body as sent
+func formatBody(body string, mode config.BodyMarkup) string {
+	switch mode {
+	case config.MarkupRaw:
+		return body
+	case config.MarkupTranslate:
+		return translateMarkup(body)
+	default:
+		return stripMarkup(body)
+	}
+}
+
+// markupToken is either a run of text or a single tag
+type markupToken struct {
+	text    string
+	tag     string
+	closing bool
+	href    string
+}
+
+// tokenizeMarkup splits body into text and tags. A '<' that doesn't start
+// a tag is kept as text, clients often send "a < b" unescaped.
+func tokenizeMarkup(body string) []markupToken {
+	var tokens []markupToken
+	var text strings.Builder
+
+	for len(body) > 0 {
+		start := strings.IndexByte(body, '<')
+		if start < 0 {
+			text.WriteString(body)
+			break
+		}
+		text.WriteString(body[:start])
+		body = body[start:]
+
+		end := strings.IndexByte(body, '>')
+		tag, ok := parseTag(body, end)
+		if !ok {
+			text.WriteByte('<')
+			body = body[1:]
+			continue
+		}
+
+		if text.Len() > 0 {
+			tokens = append(tokens, markupToken{text: html.UnescapeString(text.String())})
+			text.Reset()
+		}
+		tokens = append(tokens, tag)
+		body = body[end+1:]
+	}
+
+	if text.Len() > 0 {
+		tokens = append(tokens, markupToken{text: html.UnescapeString(text.String())})
+	}
+	return tokens
+}
+
+// parseTag reads the tag spanning body[:end+1]
+func parseTag(body string, end int) (markupToken, bool) {
+	if end < 0 {
+		return markupToken{}, false
+	}
+	inner := strings.TrimSpace(body[1:end])
+	inner = strings.TrimSuffix(inner, "/")
+
+	var token markupToken
+	if rest, ok := strings.CutPrefix(inner, "/"); ok {
+		token.closing = true
+		inner = rest
+	}
+
+	name, attrs, _ := strings.Cut(inner, " ")
+	name = strings.ToLower(name)
+	if name == "" || name[0] < 'a' || name[0] > 'z' {
+		return markupToken{}, false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return markupToken{}, false
+		}
+	}
+
+	token.tag = name
+	if name == "a" && !token.closing {
+		token.href = tagAttribute(attrs, "href")
+	}
+	return token, true
+}
+
+// tagAttribute returns the value of a quoted attribute
+func tagAttribute(attrs, name string) string {
+	for {
+		idx := strings.Index(attrs, name+"=")
+		if idx < 0 {
+			return ""
+		}
+		value := attrs[idx+len(name)+1:]
+		if len(value) > 0 && (value[0] == '"' || value[0] == '\'') {
+			if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+				return html.UnescapeString(value[1 : end+1])
+			}
+		}
+		attrs = value
+	}
+}
+
+// stripMarkup returns the body as plain text, line breaks kept
+func stripMarkup(body string) string {
+	var b strings.Builder
+	for _, token := range tokenizeMarkup(body) {
+		switch {
+		case token.tag == "br":
+			b.WriteByte('\n')
+		case token.tag == "":
+			b.WriteString(token.text)
+		}
+	}
+	return b.String()
+}
+
+// translateMarkup rewrites the body as well formed Pango markup keeping
+// only bold, italic, underline and links
+func translateMarkup(body string) string {
+	var b strings.Builder
+	var open []string
+
+	for _, token := range tokenizeMarkup(body) {
+		switch {
+		case token.tag == "":
+			b.WriteString(html.EscapeString(token.text))
+		case token.tag == "br":
+			b.WriteByte('\n')
+		case !markupTags[token.tag]:
+		case token.closing:
+			// Close only what is open, unbalanced closing tags are dropped
+			if len(open) > 0 && open[len(open)-1] == token.tag {
+				b.WriteString("</" + token.tag + ">")
+				open = open[:len(open)-1]
+			}
+		case token.tag == "a":
+			if token.href == "" {
+				continue
+			}
+			b.WriteString(`<a href="` + html.EscapeString(token.href) + `">`)
+			open = append(open, token.tag)
+		default:
+			b.WriteString("<" + token.tag + ">")
+			open = append(open, token.tag)
+		}
+	}
+
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + open[i] + ">")
+	}
+	return b.String()
+}
+
+// bareURLPattern finds URLs written as plain text
+var bareURLPattern = regexp.MustCompile(`https?://[^\s<>"']+`)
+
+// linkSchemes are the only links handed to open-command, a body comes from
+// any sender and must not reach file:// or custom URL handlers
+var linkSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// isSafeLink reports whether link may be opened
+func isSafeLink(link string) bool {
+	parsed, err := url.Parse(link)
+	return err == nil && linkSchemes[strings.ToLower(parsed.Scheme)]
+}
+
+// extractLinks collects the <a href> links and bare URLs of a body, each
+// URL once and in the order they appear
+func extractLinks(body string) []state.Link {
+	var links []state.Link
+	seen := make(map[string]bool)
+	add := func(url, text string) {
+		if url == "" || seen[url] || !isSafeLink(url) {
+			return
+		}
+		seen[url] = true
+		if text == "" {
+			text = url
+		}
+		links = append(links, state.Link{URL: url, Text: text})
+	}
+
+	var anchor *state.Link
+	for _, token := range tokenizeMarkup(body) {
+		switch {
+		case token.tag == "a" && !token.closing:
+			anchor = &state.Link{URL: token.href}
+		case token.tag == "a" && token.closing:
+			if anchor != nil {
+				add(anchor.URL, strings.TrimSpace(anchor.Text))
+				anchor = nil
+			}
+		case token.tag != "":
+		case anchor != nil:
+			anchor.Text += token.text
+		default:
+			for _, url := range bareURLPattern.FindAllString(token.text, -1) {
+				url = strings.TrimRight(url, ".,;:!?)]")
+				add(url, "")
+			}
+		}
+	}
+	if anchor != nil {
+		add(anchor.URL, strings.TrimSpace(anchor.Text))
+	}
+
+	return links
+}
+
+// openLink opens the link with the given index with the open command
+func (d *Daemon) openLink(notification state.Notification, index string) error {
+	i, err := strconv.Atoi(index)
+	if err != nil || i < 0 || i >= len(notification.Links) {
+		return fmt.Errorf("notification %d has no link %s", notification.Id, index)
+	}
+
+	command := d.cfg().Actions.OpenCommand
+	url := notification.Links[i].URL
+	if !isSafeLink(url) {
+		return fmt.Errorf("refusing to open %s, only http, https and mailto links are opened", url)
+	}
+
+	args := append([]string{}, command[1:]...)
+	// xdg-open takes no options after its own and rejects "--" itself
+	if filepath.Base(command[0]) != "xdg-open" {
+		args = append(args, "--")
+	}
+	cmd := exec.Command(command[0], append(args, url)...)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to open %s: %w", url, err)
+	}
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			slog.Warn("Opening link failed", "url", url, "err", err)
+		}
+	}()
+	return nil
+}
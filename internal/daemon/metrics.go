@@ -0,0 +1,258 @@
+package daemon
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cheezecakee/eww-notify-go/internal/state"
+	"github.com/cheezecakee/eww-notify-go/internal/util/dbus"
+)
+
+// metricKey groups the counters by app and urgency
+type metricKey struct {
+	app     string
+	urgency string
+}
+
+// closedKey adds why the notification closed
+type closedKey struct {
+	metricKey
+	reason string
+}
+
+// metrics counts what the daemon handled since it started, they live in
+// memory only, the history store keeps the long term record
+type metrics struct {
+	mu           sync.Mutex
+	started      time.Time
+	received     map[metricKey]uint64
+	closed       map[closedKey]uint64
+	ewwFailures  uint64
+	latencyTotal time.Duration
+	latencyCount uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		started:  time.Now(),
+		received: make(map[metricKey]uint64),
+		closed:   make(map[closedKey]uint64),
+	}
+}
+
+func (m *metrics) countReceived(app, urgency string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.received[metricKey{app, urgency}]++
+}
+
+func (m *metrics) countClosed(notification state.Notification, reason state.NotificationCloseReason) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	urgency := dbus.ConfigKeyUrgency(dbus.GetUrgency(notification.Hints))
+	m.closed[closedKey{metricKey{notification.AppName, urgency}, reason.String()}]++
+}
+
+func (m *metrics) countEwwFailure() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ewwFailures++
+}
+
+// observeLatency records how long a notification took from Notify to eww
+func (m *metrics) observeLatency(latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.latencyTotal += latency
+	m.latencyCount++
+}
+
+// appStats is one app's line in StatsJSON
+type appStats struct {
+	AppName   string            `json:"app_name"`
+	Received  uint64            `json:"received"`
+	ByUrgency map[string]uint64 `json:"by_urgency"`
+	Closed    map[string]uint64 `json:"closed"`
+}
+
+// StatsJSON returns the counters since the daemon started, apps sorted by
+// how many notifications they sent
+func (d *Daemon) StatsJSON() (string, error) {
+	// The state calls into the metrics with its lock held, never the
+	// other way around
+	active := len(d.state.GetNotifications())
+
+	m := d.metrics
+	m.mu.Lock()
+	apps := make(map[string]*appStats)
+	app := func(name string) *appStats {
+		if _, ok := apps[name]; !ok {
+			apps[name] = &appStats{
+				AppName:   name,
+				ByUrgency: make(map[string]uint64),
+				Closed:    make(map[string]uint64),
+			}
+		}
+		return apps[name]
+	}
+
+	var received uint64
+	for key, count := range m.received {
+		received += count
+		app(key.app).Received += count
+		app(key.app).ByUrgency[key.urgency] += count
+	}
+	closed := make(map[string]uint64)
+	for key, count := range m.closed {
+		closed[key.reason] += count
+		app(key.app).Closed[key.reason] += count
+	}
+
+	var latencyMs float64
+	if m.latencyCount > 0 {
+		latencyMs = float64(m.latencyTotal.Microseconds()) / float64(m.latencyCount) / 1000
+	}
+	stats := map[string]any{
+		"uptime":             int64(time.Since(m.started).Seconds()),
+		"active":             active,
+		"received":           received,
+		"closed":             closed,
+		"eww_failures":       m.ewwFailures,
+		"display_latency_ms": latencyMs,
+	}
+	m.mu.Unlock()
+
+	list := make([]*appStats, 0, len(apps))
+	for _, stats := range apps {
+		list = append(list, stats)
+	}
+	slices.SortFunc(list, func(a, b *appStats) int {
+		return cmp.Or(cmp.Compare(b.Received, a.Received), strings.Compare(a.AppName, b.AppName))
+	})
+	stats["apps"] = list
+
+	jsonBytes, err := json.Marshal(stats)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal stats: %w", err)
+	}
+	return string(jsonBytes), nil
+}
+
+// WritePrometheus writes the counters in the Prometheus text format
+func (d *Daemon) WritePrometheus(w io.Writer) error {
+	active := len(d.state.GetNotifications())
+
+	m := d.metrics
+	m.mu.Lock()
+
+	var b strings.Builder
+	b.WriteString("# HELP end_notifications_received_total Notifications received.\n")
+	b.WriteString("# TYPE end_notifications_received_total counter\n")
+	for _, key := range sortedKeys(m.received, func(k metricKey) string { return k.app + "\x00" + k.urgency }) {
+		fmt.Fprintf(&b, "end_notifications_received_total{app=%s,urgency=%s} %d\n",
+			promLabel(key.app), promLabel(key.urgency), m.received[key])
+	}
+
+	b.WriteString("# HELP end_notifications_closed_total Notifications closed, by reason.\n")
+	b.WriteString("# TYPE end_notifications_closed_total counter\n")
+	for _, key := range sortedKeys(m.closed, func(k closedKey) string { return k.app + "\x00" + k.urgency + "\x00" + k.reason }) {
+		fmt.Fprintf(&b, "end_notifications_closed_total{app=%s,urgency=%s,reason=%s} %d\n",
+			promLabel(key.app), promLabel(key.urgency), promLabel(key.reason), m.closed[key])
+	}
+
+	b.WriteString("# HELP end_notifications_active Notifications on screen.\n")
+	b.WriteString("# TYPE end_notifications_active gauge\n")
+	fmt.Fprintf(&b, "end_notifications_active %d\n", active)
+
+	b.WriteString("# HELP end_eww_failures_total Failed eww commands.\n")
+	b.WriteString("# TYPE end_eww_failures_total counter\n")
+	fmt.Fprintf(&b, "end_eww_failures_total %d\n", m.ewwFailures)
+
+	b.WriteString("# HELP end_display_latency_seconds Time from Notify to the eww update.\n")
+	b.WriteString("# TYPE end_display_latency_seconds summary\n")
+	fmt.Fprintf(&b, "end_display_latency_seconds_sum %g\n", m.latencyTotal.Seconds())
+	fmt.Fprintf(&b, "end_display_latency_seconds_count %d\n", m.latencyCount)
+
+	b.WriteString("# HELP end_uptime_seconds Seconds since the daemon started.\n")
+	b.WriteString("# TYPE end_uptime_seconds gauge\n")
+	fmt.Fprintf(&b, "end_uptime_seconds %d\n", int64(time.Since(m.started).Seconds()))
+	m.mu.Unlock()
+
+	// A slow reader must not hold up the counters
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// sortedKeys keeps the Prometheus output stable between scrapes
+func sortedKeys[K comparable](counters map[K]uint64, name func(K) string) []K {
+	keys := make([]K, 0, len(counters))
+	for key := range counters {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b K) int { return strings.Compare(name(a), name(b)) })
+	return keys
+}
+
+// promLabel quotes a label value for the Prometheus text format
+func promLabel(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
+
+// startMetricsServer serves /metrics for Prometheus when metrics.listen is
+// set, a failure only disables the endpoint
+func (d *Daemon) startMetricsServer() {
+	address := d.cfg().Metrics.Listen
+	if address == "" {
+		return
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		slog.Error("Metrics endpoint disabled", "address", address, "err", err)
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := d.WritePrometheus(w); err != nil {
+			slog.Debug("Failed to write metrics", "err", err)
+		}
+	})
+	d.metricsServer = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	slog.Info("Serving metrics", "address", listener.Addr().String())
+	go func() {
+		if err := d.metricsServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Metrics endpoint stopped", "err", err)
+		}
+	}()
+}
+
+// stopMetricsServer shuts the metrics endpoint down, if it runs
+func (d *Daemon) stopMetricsServer() {
+	if d.metricsServer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := d.metricsServer.Shutdown(ctx); err != nil {
+		slog.Warn("Failed to stop metrics endpoint", "err", err)
+	}
+}
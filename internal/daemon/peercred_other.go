@@ -0,0 +1,11 @@
+//go:build !linux
+
+package daemon
+
+import "net"
+
+// checkPeerCredentials is a no-op where SO_PEERCRED is unavailable, the
+// socket file permissions are the only protection there
+func checkPeerCredentials(conn net.Conn) error {
+	return nil
+}
@@ -0,0 +1,98 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cheezecakee/eww-notify-go/internal/state"
+	"github.com/cheezecakee/eww-notify-go/internal/util/constants"
+)
+
+// statePath is where the daemon saves its notifications on shutdown
+var statePath = constants.GetStatePath("")
+
+// SetStatePath changes the state file, must be called before the daemon
+// is started
+func SetStatePath(path string) {
+	statePath = path
+}
+
+// saveState writes the active notifications to the state file so the next
+// daemon can pick them up
+func (d *Daemon) saveState() error {
+	if !d.cfg().PersistState {
+		return nil
+	}
+
+	data, err := json.Marshal(d.state.Snapshot())
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(statePath), 0o700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	// Write under a temporary name so a crash never leaves half a file
+	tmp := statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := os.Rename(tmp, statePath); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	slog.Debug("Saved state", "path", statePath)
+	return nil
+}
+
+// restoreState brings back the notifications saved by the previous daemon
+// and re-arms their timeouts with the time they had left. The file is
+// removed so the same notifications never come back twice.
+func (d *Daemon) restoreState() error {
+	if !d.cfg().PersistState {
+		return nil
+	}
+
+	data, err := os.ReadFile(statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state: %w", err)
+	}
+	if err := os.Remove(statePath); err != nil {
+		slog.Warn("Failed to remove state file", "err", err)
+	}
+
+	var snapshot state.Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("failed to decode state %s: %w", statePath, err)
+	}
+
+	restored := d.state.Restore(snapshot)
+	compactAfter := time.Duration(d.cfg().CompactAfter) * time.Second
+	for _, notification := range restored {
+		if notification.Paused {
+			continue
+		}
+		age := time.Since(notification.Timestamp)
+		switch {
+		case notification.Timeout > 0:
+			d.scheduleTimeout(notification.Id, notification.Timeout-age)
+		case compactAfter > 0 && !notification.Compact:
+			d.scheduleCompact(notification.Id, compactAfter-age)
+		}
+	}
+
+	slog.Info("Restored notifications", "count", len(restored), "path", statePath)
+	if snapshot.Dnd {
+		d.publishDnd()
+	}
+	return d.updateDisplay()
+}
@@ -0,0 +1,303 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+
+	"github.com/cheezecakee/eww-notify-go/internal/state"
+	notify "github.com/cheezecakee/eww-notify-go/internal/util/dbus"
+)
+
+const (
+	PortalBusName    = "org.freedesktop.impl.portal.desktop.end"
+	PortalObjectPath = "/org/freedesktop/portal/desktop"
+	PortalInterface  = "org.freedesktop.impl.portal.Notification"
+)
+
+// portalKey identifies a portal notification, the ID is chosen by the app
+type portalKey struct {
+	appId string
+	id    string
+}
+
+// portalNotification is what ActionInvoked needs to answer the portal
+type portalNotification struct {
+	key           portalKey
+	defaultAction string
+	targets       map[string]dbus.Variant
+}
+
+// PortalServer implements the notification backend of xdg-desktop-portal,
+// so sandboxed apps end up in the same stack as everyone else
+type PortalServer struct {
+	daemon *Daemon
+	conn   *dbus.Conn
+
+	mu    sync.Mutex
+	byKey map[portalKey]uint32
+	byId  map[uint32]*portalNotification
+}
+
+// setupPortal exports the portal backend under its own bus name
+func (ns *NotificationServer) setupPortal() error {
+	portal := &PortalServer{
+		daemon: ns.daemon,
+		conn:   ns.conn,
+		byKey:  make(map[portalKey]uint32),
+		byId:   make(map[uint32]*portalNotification),
+	}
+
+	reply, err := ns.conn.RequestName(PortalBusName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return fmt.Errorf("failed to request portal name: %w", err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return fmt.Errorf("%s is owned by another process", PortalBusName)
+	}
+
+	if err := ns.conn.Export(portal, PortalObjectPath, PortalInterface); err != nil {
+		return fmt.Errorf("failed to export portal interface: %w", err)
+	}
+	err = ns.conn.Export(introspect.Introspectable(portal.introspectData()), PortalObjectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		return fmt.Errorf("failed to export portal introspection: %w", err)
+	}
+
+	ns.daemon.portal = portal
+	slog.Debug("Portal backend exported", "name", PortalBusName)
+	return nil
+}
+
+func (ps *PortalServer) AddNotification(sender dbus.Sender, appId, id string, notification map[string]dbus.Variant) (dbusErr *dbus.Error) {
+	defer recoverCall("Portal.AddNotification", &dbusErr)
+	slog.Debug("Portal AddNotification called", "app", appId, "portal_id", id)
+	key := portalKey{appId: appId, id: id}
+
+	ps.mu.Lock()
+	replaceId := ps.byKey[key]
+	ps.mu.Unlock()
+
+	title, _ := notification["title"].Value().(string)
+	body, _ := notification["body"].Value().(string)
+	if markup, ok := notification["markup-body"].Value().(string); ok {
+		body = markup
+	}
+	priority, _ := notification["priority"].Value().(string)
+
+	hints := map[string]any{
+		notify.HintKeyUrgency: portalUrgency(priority),
+	}
+	// Flatpak app IDs double as desktop entry IDs
+	if appId != "" {
+		hints[notify.HintKeyDesktopEntry] = appId
+	}
+	if category, ok := notification["category"].Value().(string); ok {
+		hints[notify.HintKeyCategory] = category
+	}
+
+	appIcon, imagePath := portalIcon(notification["icon"])
+	if imagePath != "" {
+		hints["image-path"] = imagePath
+	}
+
+	actions, routed := portalActions(notification)
+	routed.key = key
+
+	appName := appId
+	if appName == "" {
+		appName = "Unknown"
+	}
+
+	notificationId, err := ps.daemon.HandleNotification(string(sender), appName, replaceId, appIcon, title, body, actions, hints, -1)
+
+	// The notification may be on screen even if updating eww failed
+	if notificationId != 0 {
+		ps.mu.Lock()
+		ps.prune()
+		ps.byKey[key] = notificationId
+		ps.byId[notificationId] = routed
+		ps.mu.Unlock()
+	}
+
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+func (ps *PortalServer) RemoveNotification(appId, id string) (dbusErr *dbus.Error) {
+	defer recoverCall("Portal.RemoveNotification", &dbusErr)
+	slog.Debug("Portal RemoveNotification called", "app", appId, "portal_id", id)
+	key := portalKey{appId: appId, id: id}
+
+	ps.mu.Lock()
+	notificationId, ok := ps.byKey[key]
+	delete(ps.byKey, key)
+	delete(ps.byId, notificationId)
+	ps.mu.Unlock()
+
+	// Removing a notification that is already gone is not an error
+	if !ok || ps.daemon.closeNotification(notificationId, state.CloseNotification) != nil {
+		return nil
+	}
+	return ps.daemon.dbusServer.HandleDBusError(ps.daemon.dbusServer.EmitNotificationClosed(notificationId, state.CloseNotification))
+}
+
+// prune forgets notifications that have left the screen
+// Caller must hold the lock
+func (ps *PortalServer) prune() {
+	for notificationId, routed := range ps.byId {
+		if !ps.daemon.state.IsLive(notificationId) {
+			delete(ps.byId, notificationId)
+			delete(ps.byKey, routed.key)
+		}
+	}
+}
+
+// invokeAction answers an action on a portal notification through the
+// portal, reporting false for notifications the portal did not send
+func (ps *PortalServer) invokeAction(id uint32, actionKey string) (bool, error) {
+	if ps == nil {
+		return false, nil
+	}
+
+	ps.mu.Lock()
+	routed, ok := ps.byId[id]
+	ps.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+
+	action := actionKey
+	if actionKey == "default" {
+		action = routed.defaultAction
+	}
+
+	parameter := []dbus.Variant{}
+	if target, ok := routed.targets[actionKey]; ok {
+		parameter = append(parameter, target)
+	}
+
+	slog.Debug("Emitting portal ActionInvoked", "app", routed.key.appId, "portal_id", routed.key.id, "action", action)
+	ps.daemon.events.publish(Event{Event: "action", Id: id, ActionKey: actionKey})
+	return true, ps.conn.Emit(
+		PortalObjectPath,
+		PortalInterface+".ActionInvoked",
+		routed.key.appId,
+		routed.key.id,
+		action,
+		parameter,
+	)
+}
+
+// portalUrgency maps the portal's four priorities onto the spec's urgency
+func portalUrgency(priority string) uint8 {
+	switch priority {
+	case "low":
+		return 0
+	case "urgent":
+		return 2
+	default: // "normal", "high"
+		return 1
+	}
+}
+
+// portalActions turns the default action and buttons into spec actions
+func portalActions(notification map[string]dbus.Variant) ([]string, *portalNotification) {
+	routed := &portalNotification{targets: make(map[string]dbus.Variant)}
+
+	var actions []string
+	if action, ok := notification["default-action"].Value().(string); ok && action != "" {
+		routed.defaultAction = action
+		actions = append(actions, "default", "")
+		if target, ok := notification["default-action-target"]; ok {
+			routed.targets["default"] = target
+		}
+	}
+
+	buttons, _ := notification["buttons"].Value().([]map[string]dbus.Variant)
+	for _, button := range buttons {
+		action, _ := button["action"].Value().(string)
+		label, _ := button["label"].Value().(string)
+		if action == "" {
+			continue
+		}
+		actions = append(actions, action, label)
+		if target, ok := button["target"]; ok {
+			routed.targets[action] = target
+		}
+	}
+
+	return actions, routed
+}
+
+// portalIcon reads a serialized GIcon, returning a themed icon name or the
+// path of the image it carried
+func portalIcon(icon dbus.Variant) (string, string) {
+	serialized, ok := icon.Value().([]any)
+	if !ok || len(serialized) != 2 {
+		return "", ""
+	}
+	kind, _ := serialized[0].(string)
+	value, _ := serialized[1].(dbus.Variant)
+
+	var data []byte
+	switch kind {
+	case "themed":
+		if names, ok := value.Value().([]string); ok && len(names) > 0 {
+			return names[0], ""
+		}
+		return "", ""
+	case "file":
+		path, _ := value.Value().(string)
+		return "", fileURIPath(path)
+	case "bytes":
+		data, _ = value.Value().([]byte)
+	case "file-descriptor":
+		fd, ok := value.Value().(dbus.UnixFD)
+		if !ok {
+			return "", ""
+		}
+		file := os.NewFile(uintptr(fd), "icon")
+		defer file.Close()
+		data, _ = io.ReadAll(file)
+	}
+
+	if len(data) == 0 {
+		return "", ""
+	}
+	path, err := saveEncodedImage(data)
+	if err != nil {
+		slog.Warn("Failed to save portal notification icon", "err", err)
+		return "", ""
+	}
+	return "", path
+}
+
+func (ps *PortalServer) introspectData() string {
+	return `<node>
+	<interface name="` + PortalInterface + `">
+		<method name="AddNotification">
+			<arg direction="in" name="app_id" type="s"/>
+			<arg direction="in" name="id" type="s"/>
+			<arg direction="in" name="notification" type="a{sv}"/>
+		</method>
+		<method name="RemoveNotification">
+			<arg direction="in" name="app_id" type="s"/>
+			<arg direction="in" name="id" type="s"/>
+		</method>
+		<signal name="ActionInvoked">
+			<arg name="app_id" type="s"/>
+			<arg name="id" type="s"/>
+			<arg name="action" type="s"/>
+			<arg name="parameter" type="av"/>
+		</signal>
+	</interface>
+</node>`
+}
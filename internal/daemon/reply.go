@@ -0,0 +1,63 @@
+package daemon
+
+import (
+	"fmt"
+
+	"github.com/cheezecakee/eww-notify-go/internal/state"
+	"github.com/cheezecakee/eww-notify-go/internal/util/dbus"
+)
+
+// ReplyActionKey is the action a client adds to ask for a reply text field,
+// its label names the field's submit button
+const ReplyActionKey = "inline-reply"
+
+// replyAction returns the label of notification's inline reply action
+func replyAction(notification state.Notification) (string, bool) {
+	actions := notification.Actions
+	for i := 0; i+1 < len(actions); i += 2 {
+		if actions[i] == ReplyActionKey {
+			return actions[i+1], true
+		}
+	}
+	return "", false
+}
+
+// replyData describes the reply field for the widget, nil when the
+// notification does not accept replies
+func replyData(notification state.Notification) map[string]string {
+	label, ok := replyAction(notification)
+	if !ok {
+		return nil
+	}
+
+	if submit, ok := dbus.GetStringHint(notification.Hints, dbus.HintKeyReplySubmitLabel); ok && submit != "" {
+		label = submit
+	}
+	placeholder, _ := dbus.GetStringHint(notification.Hints, dbus.HintKeyReplyPlaceholder)
+
+	return map[string]string{
+		"label":       label,
+		"placeholder": placeholder,
+	}
+}
+
+// Reply sends text to the application as the inline reply to notification
+// id, the notification is closed like after any other action
+func (d *Daemon) Reply(id uint32, text string) error {
+	notification, exists := d.state.GetNotificationsById(id)
+	if !exists {
+		return fmt.Errorf("notification with ID %d not found", id)
+	}
+	if _, ok := replyAction(notification); !ok {
+		return fmt.Errorf("notification with ID %d does not accept replies", id)
+	}
+	if text == "" {
+		return fmt.Errorf("reply text is empty")
+	}
+
+	if err := d.dbusServer.EmitNotificationReplied(id, text); err != nil {
+		return err
+	}
+
+	return d.closeAfterAction(id)
+}
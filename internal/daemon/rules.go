@@ -0,0 +1,76 @@
+package daemon
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+
+	"github.com/cheezecakee/eww-notify-go/internal/config"
+	"github.com/cheezecakee/eww-notify-go/internal/state"
+)
+
+// ruleOutcome is the combined effect of every rule matching a notification
+type ruleOutcome struct {
+	timeout     *config.Duration
+	widget      *string
+	urgency     config.Urgency
+	skipDisplay bool
+	historyOnly bool
+	scripts     []string
+}
+
+// matchRules applies the configured rules in order, later rules override
+// the settings of earlier ones and scripts accumulate
+func (d *Daemon) matchRules(subject config.RuleSubject) ruleOutcome {
+	var outcome ruleOutcome
+	for _, rule := range d.cfg().Rules {
+		if !rule.Matches(subject) {
+			continue
+		}
+
+		if rule.Timeout != nil {
+			outcome.timeout = rule.Timeout
+		}
+		if rule.Widget != nil {
+			outcome.widget = rule.Widget
+		}
+		if rule.SetUrgency != "" {
+			outcome.urgency = rule.SetUrgency
+		}
+		outcome.skipDisplay = outcome.skipDisplay || rule.SkipDisplay
+		outcome.historyOnly = outcome.historyOnly || rule.HistoryOnly
+		if rule.Script != "" {
+			outcome.scripts = append(outcome.scripts, rule.Script)
+		}
+	}
+	return outcome
+}
+
+// urgencyHint converts an urgency name back to the byte used in hints
+func urgencyHint(urgency config.Urgency) uint8 {
+	switch urgency {
+	case config.UrgencyLow:
+		return 0
+	case config.UrgencyCritical:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// runRuleScript starts a rule's script with the notification in its
+// environment, without waiting for it
+func runRuleScript(script string, notification state.Notification) {
+	cmd := exec.Command(script)
+	cmd.Env = append(os.Environ(), notificationEnv(notification)...)
+
+	if err := cmd.Start(); err != nil {
+		slog.Error("Failed to run rule script", "script", script, "id", notification.Id, "err", err)
+		return
+	}
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			slog.Warn("Rule script failed", "script", script, "id", notification.Id, "err", err)
+		}
+	}()
+}
@@ -0,0 +1,86 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cheezecakee/eww-notify-go/internal/util/xdg"
+)
+
+// DBusServicePath is where the session bus looks for the service file that
+// starts the daemon on the first Notify call
+func DBusServicePath() (string, error) {
+	dataHome := xdg.DataHome()
+	if dataHome == "" {
+		return "", fmt.Errorf("cannot locate the data directory, set $XDG_DATA_HOME")
+	}
+	return filepath.Join(dataHome, "dbus-1", "services", NotificationServiceName+".service"), nil
+}
+
+// DBusServiceFile returns a D-Bus service file that activates executable
+// for org.freedesktop.Notifications
+func DBusServiceFile(executable string) string {
+	return fmt.Sprintf("[D-BUS Service]\nName=%s\nExec=%s\n", NotificationServiceName, executable)
+}
+
+// InstallDBusService writes the service file for the running executable,
+// an existing file is only replaced when force is set
+func InstallDBusService(force bool) (string, error) {
+	path, err := DBusServicePath()
+	if err != nil {
+		return "", err
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the executable: %w", err)
+	}
+
+	if err := writeDataFile(path, DBusServiceFile(executable), force); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// PortalFile registers the daemon with xdg-desktop-portal as a backend for
+// notifications
+func PortalFile() string {
+	return fmt.Sprintf("[portal]\nDBusName=%s\nInterfaces=%s;\n", PortalBusName, PortalInterface)
+}
+
+// InstallPortal writes the portal file, an existing file is only replaced
+// when force is set. The portal picks the backend up once portals.conf
+// names it, see PortalConfLine.
+func InstallPortal(force bool) (string, error) {
+	dataHome := xdg.DataHome()
+	if dataHome == "" {
+		return "", fmt.Errorf("cannot locate the data directory, set $XDG_DATA_HOME")
+	}
+	path := filepath.Join(dataHome, "xdg-desktop-portal", "portals", "end.portal")
+
+	if err := writeDataFile(path, PortalFile(), force); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// PortalConfLine is the portals.conf entry selecting this backend
+const PortalConfLine = PortalInterface + "=end"
+
+func writeDataFile(path, content string, force bool) error {
+	if !force {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists, use --force to overwrite it", path)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package daemon
+
+import (
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/cheezecakee/eww-notify-go/internal/config"
+	"github.com/cheezecakee/eww-notify-go/internal/util/dbus"
+)
+
+// playSound plays the sound for a notification that was just shown. The
+// client's sound-file or sound-name hint wins over the configured sound,
+// which is the category or type sound when set and the urgency sound
+// otherwise. Replacements only make a sound when they ask for one.
+func (d *Daemon) playSound(hints map[string]any, urgencyKey, sound string, replaced bool) {
+	cfg := d.cfg().Sound
+	if !cfg.Enabled {
+		return
+	}
+
+	if suppress, _ := dbus.GetBoolHint(hints, dbus.HintKeySuppressSound); suppress {
+		return
+	}
+
+	if file, ok := dbus.GetStringHint(hints, dbus.HintKeySoundFile); ok && file != "" {
+		runSoundCommand(cfg.FileCommand, fileURIPath(file))
+		return
+	}
+	if name, ok := dbus.GetStringHint(hints, dbus.HintKeySoundName); ok && name != "" {
+		runSoundCommand(cfg.NameCommand, name)
+		return
+	}
+	if replaced {
+		return
+	}
+
+	if sound == "" {
+		sound = urgencySound(cfg.ByUrgency, urgencyKey)
+	}
+	switch {
+	case sound == "":
+	case filepath.IsAbs(sound):
+		runSoundCommand(cfg.FileCommand, sound)
+	default:
+		runSoundCommand(cfg.NameCommand, sound)
+	}
+}
+
+func urgencySound(sounds config.SoundByUrgency, urgencyKey string) string {
+	switch urgencyKey {
+	case "low":
+		return sounds.Low
+	case "critical":
+		return sounds.Critical
+	default:
+		return sounds.Normal
+	}
+}
+
+// runSoundCommand starts command with the sound appended, without waiting
+// for playback to finish
+func runSoundCommand(command []string, sound string) {
+	if len(command) == 0 {
+		return
+	}
+
+	args := append(append([]string{}, command[1:]...), sound)
+	cmd := exec.Command(command[0], args...)
+	if err := cmd.Start(); err != nil {
+		slog.Error("Failed to play sound", "sound", sound, "err", err)
+		return
+	}
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			slog.Warn("Sound command failed", "sound", sound, "err", err)
+		}
+	}()
+}
@@ -0,0 +1,137 @@
+package daemon
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// replaceStormWindow is the period replaces are counted over
+const replaceStormWindow = time.Second
+
+// stormGuard tracks how often each app replaces its notifications, so an app
+// stuck in a replace loop can't make the daemon re-render eww continuously
+type stormGuard struct {
+	mu   sync.Mutex
+	apps map[string]*replaceCounter
+}
+
+type replaceCounter struct {
+	windowStart time.Time
+	count       uint32
+	throttled   bool
+	// flushPending is set while a deferred display update is scheduled
+	flushPending bool
+}
+
+// recordReplace counts a replace from appName and reports whether the app
+// is over the limit, along with whether this call started the storm
+func (g *stormGuard) recordReplace(appName string, limit uint32) (throttled, started bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.apps == nil {
+		g.apps = make(map[string]*replaceCounter)
+	}
+
+	counter, ok := g.apps[appName]
+	if !ok {
+		counter = &replaceCounter{}
+		g.apps[appName] = counter
+	}
+
+	now := time.Now()
+	if now.Sub(counter.windowStart) >= replaceStormWindow {
+		// A quiet window ends the storm
+		if counter.count <= limit {
+			counter.throttled = false
+		}
+		counter.windowStart = now
+		counter.count = 0
+	}
+
+	counter.count++
+	if counter.count > limit && !counter.throttled {
+		counter.throttled = true
+		return true, true
+	}
+	return counter.throttled, false
+}
+
+// claimFlush reports whether the caller should schedule the deferred
+// display update for a throttled app
+func (g *stormGuard) claimFlush(appName string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	counter := g.apps[appName]
+	if counter == nil || counter.flushPending {
+		return false
+	}
+	counter.flushPending = true
+	return true
+}
+
+func (g *stormGuard) releaseFlush(appName string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if counter := g.apps[appName]; counter != nil {
+		counter.flushPending = false
+	}
+}
+
+// throttleReplace decides whether a replace from appName should update the
+// display right away. While an app is storming, its replaces only update
+// state and the display is refreshed once per window with the latest content.
+func (d *Daemon) throttleReplace(appName string) bool {
+	cfg := d.cfg().ReplaceStorm
+	if cfg.MaxPerSecond == 0 {
+		return false
+	}
+
+	throttled, started := d.storm.recordReplace(appName, cfg.MaxPerSecond)
+	if !throttled {
+		return false
+	}
+
+	if started {
+		slog.Warn("Replace storm, throttling", "app", appName, "max_per_second", cfg.MaxPerSecond)
+		if cfg.Notify {
+			go d.notifyMisbehaving(appName)
+		}
+	}
+
+	if d.storm.claimFlush(appName) {
+		go func() {
+			select {
+			case <-time.After(replaceStormWindow):
+				d.storm.releaseFlush(appName)
+				d.updateDisplay()
+			case <-d.ctx.Done():
+			}
+		}()
+	}
+
+	return true
+}
+
+// notifyMisbehaving posts a notification from the daemon itself about an
+// app caught in a replace storm
+func (d *Daemon) notifyMisbehaving(appName string) {
+	_, err := d.HandleNotification(
+		"",
+		"eww-notify",
+		0,
+		"dialog-warning",
+		fmt.Sprintf("%s is misbehaving", appName),
+		"It is replacing its notifications hundreds of times per second, updates are being throttled.",
+		nil,
+		map[string]any{"urgency": uint8(1)},
+		-1,
+	)
+	if err != nil {
+		slog.Error("Failed to post replace storm warning", "err", err)
+	}
+}
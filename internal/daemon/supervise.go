@@ -0,0 +1,56 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// supervisorBackoff is how long a loop that panicked waits before it runs
+// again, so one that panics right away does not spin
+const supervisorBackoff = time.Second
+
+// logPanic reports a recovered panic with the stack that raised it
+func logPanic(where string, value any) {
+	slog.Error("Recovered from panic", "in", where, "panic", value, "stack", string(debug.Stack()))
+}
+
+// supervise runs loop until it returns or ctx is done, restarting it after
+// a panic. One crashing loop must not take the display pipeline down.
+func supervise(ctx context.Context, name string, loop func()) {
+	for runRecovered(name, loop) {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(supervisorBackoff):
+		}
+		slog.Warn("Restarting after panic", "loop", name)
+	}
+}
+
+// runRecovered runs fn, reporting whether it panicked
+func runRecovered(name string, fn func()) (panicked bool) {
+	defer func() {
+		if value := recover(); value != nil {
+			logPanic(name, value)
+			panicked = true
+		}
+	}()
+
+	fn()
+	return false
+}
+
+// recoverCall turns a panic in a D-Bus method into an error reply, godbus
+// runs every call in its own goroutine and would let it crash the daemon.
+// Deferred directly by the method with its named error result.
+func recoverCall(method string, dbusErr **dbus.Error) {
+	if value := recover(); value != nil {
+		logPanic(method, value)
+		*dbusErr = dbus.MakeFailedError(fmt.Errorf("internal error in %s", method))
+	}
+}
@@ -0,0 +1,105 @@
+package daemon
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// HistoryActionKey is the synthetic action on suppression summaries that
+// opens the notification center
+const HistoryActionKey = "__history"
+
+// summaryAppName is the app the suppression summaries are posted as
+const summaryAppName = "eww-notify"
+
+// suppressionTracker counts notifications dropped per app since the last
+// summary was posted
+type suppressionTracker struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (t *suppressionTracker) record(appName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.counts == nil {
+		t.counts = make(map[string]int)
+	}
+	t.counts[appName]++
+}
+
+// drain returns the counts collected so far and resets them
+func (t *suppressionTracker) drain() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	counts := t.counts
+	t.counts = nil
+	return counts
+}
+
+// recordSuppressed notes that a notification from appName was dropped
+func (d *Daemon) recordSuppressed(appName string) {
+	// A dropped summary must not report itself again and again
+	if d.cfg().SuppressedSummary.Interval == 0 || appName == summaryAppName {
+		return
+	}
+	d.suppressed.record(appName)
+}
+
+// suppressedSummaryLoop periodically posts one notification per app whose
+// notifications were dropped, so suppression never silently loses context
+func (d *Daemon) suppressedSummaryLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			counts := d.suppressed.drain()
+			apps := make([]string, 0, len(counts))
+			for app := range counts {
+				apps = append(apps, app)
+			}
+			slices.Sort(apps)
+
+			for _, app := range apps {
+				d.postSuppressedSummary(app, counts[app])
+			}
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *Daemon) postSuppressedSummary(appName string, count int) {
+	summary := fmt.Sprintf("%d notifications suppressed from %s", count, appName)
+	if count == 1 {
+		summary = fmt.Sprintf("1 notification suppressed from %s", appName)
+	}
+
+	// The history action opens the center, offer it only when there is one
+	var actions []string
+	if d.cfg().CenterWindow != nil {
+		actions = []string{HistoryActionKey, "Open history"}
+	}
+
+	_, err := d.HandleNotification(
+		"",
+		summaryAppName,
+		0,
+		"dialog-information",
+		summary,
+		"",
+		actions,
+		map[string]any{"urgency": uint8(0)},
+		-1,
+	)
+	if err != nil {
+		slog.Error("Failed to post suppression summary", "err", err)
+	}
+}
@@ -0,0 +1,181 @@
+package daemon
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// timerKind is what happens to a notification when its timer fires
+type timerKind int
+
+const (
+	timerExpire timerKind = iota
+	timerCompact
+)
+
+// timer is a pending expiry or compaction of one notification
+type timer struct {
+	id    uint32
+	kind  timerKind
+	at    time.Time
+	index int
+}
+
+// timerHeap orders timers by deadline, soonest first
+type timerHeap []*timer
+
+func (h timerHeap) Len() int           { return len(h) }
+func (h timerHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x any) {
+	t := x.(*timer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() any {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	t.index = -1
+	return t
+}
+
+// timerManager owns every notification timer, one goroutine waits for the
+// soonest deadline instead of one goroutine per notification. Each
+// notification has at most one timer, scheduling replaces it.
+type timerManager struct {
+	mu     sync.Mutex
+	timers timerHeap
+	byId   map[uint32]*timer
+	wake   chan struct{}
+}
+
+// init prepares the manager on first use
+// Caller must hold the lock
+func (m *timerManager) init() {
+	if m.byId == nil {
+		m.byId = make(map[uint32]*timer)
+		m.wake = make(chan struct{}, 1)
+	}
+}
+
+// schedule fires the notification's timer after duration, replacing any
+// timer it already had
+func (m *timerManager) schedule(id uint32, kind timerKind, duration time.Duration) {
+	m.mu.Lock()
+	m.init()
+
+	at := time.Now().Add(duration)
+	if t, ok := m.byId[id]; ok {
+		t.kind = kind
+		t.at = at
+		heap.Fix(&m.timers, t.index)
+	} else {
+		t := &timer{id: id, kind: kind, at: at}
+		heap.Push(&m.timers, t)
+		m.byId[id] = t
+	}
+	wake := m.wake
+	m.mu.Unlock()
+
+	m.notify(wake)
+}
+
+// cancel drops the notification's timer, if it has one
+func (m *timerManager) cancel(id uint32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if t, ok := m.byId[id]; ok {
+		heap.Remove(&m.timers, t.index)
+		delete(m.byId, id)
+	}
+}
+
+// cancelAll drops every timer
+func (m *timerManager) cancelAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.timers = nil
+	clear(m.byId)
+}
+
+// pending returns how many timers are waiting to fire
+func (m *timerManager) pending() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.timers)
+}
+
+// notify wakes run to look at the soonest deadline again
+func (m *timerManager) notify(wake chan struct{}) {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// run fires timers as they come due until ctx is done. fire is called from
+// this goroutine, without the lock held, so it may schedule new timers.
+func (m *timerManager) run(ctx context.Context, fire func(id uint32, kind timerKind)) {
+	m.mu.Lock()
+	m.init()
+	wake := m.wake
+	m.mu.Unlock()
+
+	clock := time.NewTimer(time.Hour)
+	defer clock.Stop()
+
+	for {
+		// A timer that panics must not take the ones due with it
+		for _, t := range m.due(time.Now()) {
+			runRecovered("timer", func() { fire(t.id, t.kind) })
+		}
+
+		clock.Reset(m.untilNext())
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-clock.C:
+		}
+	}
+}
+
+// due removes and returns the timers whose deadline has passed
+func (m *timerManager) due(now time.Time) []*timer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var fired []*timer
+	for len(m.timers) > 0 && !m.timers[0].at.After(now) {
+		t := heap.Pop(&m.timers).(*timer)
+		delete(m.byId, t.id)
+		fired = append(fired, t)
+	}
+	return fired
+}
+
+// untilNext returns the wait until the soonest deadline, an hour when
+// nothing is scheduled
+func (m *timerManager) untilNext() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.timers) == 0 {
+		return time.Hour
+	}
+	return max(0, time.Until(m.timers[0].at))
+}
@@ -0,0 +1,168 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/cheezecakee/eww-notify-go/internal/config"
+)
+
+// resolveMonitor finds the output showing the window of the process that
+// sent a notification, falling back to the focused output
+func (d *Daemon) resolveMonitor(sender string) (string, error) {
+	routing := d.cfg().WorkspaceRouting
+	if routing == config.RoutingOff {
+		return "", nil
+	}
+
+	var windows map[uint32]string
+	var focused string
+	var err error
+	switch routing {
+	case config.RoutingHyprland:
+		windows, focused, err = hyprlandOutputs()
+	case config.RoutingSway:
+		windows, focused, err = swayOutputs()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	pid, err := d.dbusServer.senderPid(sender)
+	if err != nil {
+		return focused, nil
+	}
+
+	// The D-Bus client is often a helper process of the app owning the window
+	for pid > 1 {
+		if output, ok := windows[pid]; ok {
+			return output, nil
+		}
+		pid = parentPid(pid)
+	}
+
+	return focused, nil
+}
+
+// hyprlandOutputs maps window pids to monitor names using hyprctl
+func hyprlandOutputs() (map[uint32]string, string, error) {
+	var monitors []struct {
+		Id      int    `json:"id"`
+		Name    string `json:"name"`
+		Focused bool   `json:"focused"`
+	}
+	if err := runJSON(&monitors, "hyprctl", "monitors", "-j"); err != nil {
+		return nil, "", err
+	}
+
+	names := make(map[int]string, len(monitors))
+	focused := ""
+	for _, monitor := range monitors {
+		names[monitor.Id] = monitor.Name
+		if monitor.Focused {
+			focused = monitor.Name
+		}
+	}
+
+	var clients []struct {
+		Pid     uint32 `json:"pid"`
+		Monitor int    `json:"monitor"`
+	}
+	if err := runJSON(&clients, "hyprctl", "clients", "-j"); err != nil {
+		return nil, "", err
+	}
+
+	windows := make(map[uint32]string, len(clients))
+	for _, client := range clients {
+		windows[client.Pid] = names[client.Monitor]
+	}
+	return windows, focused, nil
+}
+
+type swayNode struct {
+	Type          string     `json:"type"`
+	Name          string     `json:"name"`
+	Pid           uint32     `json:"pid"`
+	Nodes         []swayNode `json:"nodes"`
+	FloatingNodes []swayNode `json:"floating_nodes"`
+}
+
+// swayOutputs maps window pids to output names using swaymsg
+func swayOutputs() (map[uint32]string, string, error) {
+	var outputs []struct {
+		Name    string `json:"name"`
+		Focused bool   `json:"focused"`
+	}
+	if err := runJSON(&outputs, "swaymsg", "-t", "get_outputs", "-r"); err != nil {
+		return nil, "", err
+	}
+
+	focused := ""
+	for _, output := range outputs {
+		if output.Focused {
+			focused = output.Name
+		}
+	}
+
+	var tree swayNode
+	if err := runJSON(&tree, "swaymsg", "-t", "get_tree", "-r"); err != nil {
+		return nil, "", err
+	}
+
+	windows := make(map[uint32]string)
+	var walk func(node swayNode, output string)
+	walk = func(node swayNode, output string) {
+		if node.Type == "output" {
+			output = node.Name
+		}
+		if node.Pid != 0 {
+			windows[node.Pid] = output
+		}
+		for _, child := range append(node.Nodes, node.FloatingNodes...) {
+			walk(child, output)
+		}
+	}
+	walk(tree, "")
+
+	return windows, focused, nil
+}
+
+func runJSON(v any, name string, args ...string) error {
+	output, err := exec.Command(name, args...).Output()
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", name, err)
+	}
+	if err := json.Unmarshal(output, v); err != nil {
+		return fmt.Errorf("failed to parse %s output: %w", name, err)
+	}
+	return nil
+}
+
+// parentPid reads the parent of a process from /proc, 0 if unknown
+func parentPid(pid uint32) uint32 {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0
+	}
+
+	// The command name may contain spaces and parentheses, fields resume
+	// after the last ')'
+	end := strings.LastIndex(string(stat), ")")
+	if end < 0 {
+		return 0
+	}
+	fields := strings.Fields(string(stat)[end+1:])
+	if len(fields) < 2 {
+		return 0
+	}
+
+	ppid, err := strconv.ParseUint(fields[1], 10, 32)
+	if err != nil {
+		return 0
+	}
+	return uint32(ppid)
+}
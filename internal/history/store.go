@@ -0,0 +1,306 @@
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Record is a closed notification kept in the persistent history
+type Record struct {
+	Id         uint32    `json:"id"`
+	AppName    string    `json:"app_name"`
+	Summary    string    `json:"summary"`
+	Body       string    `json:"body"`
+	Urgency    string    `json:"urgency"`
+	Category   string    `json:"category,omitempty"`
+	Received   time.Time `json:"received"`
+	Closed     time.Time `json:"closed"`
+	Reason     string    `json:"reason"`
+	ReasonCode uint32    `json:"reason_code"`
+}
+
+// Retention bounds what the store keeps, zero values keep everything
+type Retention struct {
+	MaxAge     time.Duration
+	MaxEntries int
+}
+
+// Query selects records, zero values match everything
+type Query struct {
+	AppName string
+	Since   time.Time
+	Until   time.Time
+	// Text must appear in the summary or body, every word somewhere,
+	// ignoring case
+	Text  string
+	Limit int
+}
+
+var (
+	// recordsBucket holds the JSON records keyed by closing time
+	recordsBucket = []byte("records")
+	// appsBucket holds one bucket per lower-cased app name with the keys
+	// of that app's records
+	appsBucket = []byte("apps")
+)
+
+// Store is a bbolt database of records ordered by when they closed, so
+// time ranges and per-app queries only read the records they return
+type Store struct {
+	mu        sync.Mutex
+	db        *bolt.DB
+	count     int
+	retention Retention
+}
+
+// Open opens the store at path, creating it if needed
+func Open(path string, retention Retention) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	// Another daemon using the same file holds its lock
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+
+	s := &Store{db: db, retention: retention}
+	err = db.Update(func(tx *bolt.Tx) error {
+		records, err := tx.CreateBucketIfNotExists(recordsBucket)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(appsBucket); err != nil {
+			return err
+		}
+		s.count = records.Stats().KeyN
+		return s.applyRetention(tx, time.Now())
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	return s, nil
+}
+
+// Add stores records in one transaction
+func (s *Store) Add(records ...Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		return fmt.Errorf("history store is closed")
+	}
+
+	count := s.count
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(recordsBucket)
+		for _, record := range records {
+			data, err := json.Marshal(record)
+			if err != nil {
+				return fmt.Errorf("failed to encode history record: %w", err)
+			}
+			seq, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			key := recordKey(record.Closed, seq)
+			if err := bucket.Put(key, data); err != nil {
+				return err
+			}
+			apps, err := tx.Bucket(appsBucket).CreateBucketIfNotExists(appKey(record.AppName))
+			if err != nil {
+				return err
+			}
+			if err := apps.Put(key, nil); err != nil {
+				return err
+			}
+			s.count++
+		}
+		return s.applyRetention(tx, time.Now())
+	})
+	if err != nil {
+		// The transaction was rolled back
+		s.count = count
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// Query returns the matching records, newest first
+func (s *Store) Query(query Query) []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		return nil
+	}
+
+	words := strings.Fields(strings.ToLower(query.Text))
+
+	var matches []Record
+	s.db.View(func(tx *bolt.Tx) error {
+		records := tx.Bucket(recordsBucket)
+
+		// Walk the app's index when one is asked for, all records otherwise
+		keys := records
+		if query.AppName != "" {
+			keys = tx.Bucket(appsBucket).Bucket(appKey(query.AppName))
+			if keys == nil {
+				return nil
+			}
+		}
+
+		cursor := keys.Cursor()
+		key, _ := cursor.Last()
+		if !query.Until.IsZero() {
+			// Start at the last record closed at or before Until
+			if key, _ = cursor.Seek(recordKey(query.Until.Add(time.Nanosecond), 0)); key == nil {
+				key, _ = cursor.Last()
+			} else {
+				key, _ = cursor.Prev()
+			}
+		}
+
+		var since []byte
+		if !query.Since.IsZero() {
+			since = recordKey(query.Since, 0)
+		}
+
+		for ; key != nil; key, _ = cursor.Prev() {
+			if query.Limit > 0 && len(matches) >= query.Limit {
+				break
+			}
+			if since != nil && bytes.Compare(key, since) < 0 {
+				break
+			}
+
+			var record Record
+			if err := json.Unmarshal(records.Get(key), &record); err != nil {
+				continue
+			}
+			if !matchesText(record, words) {
+				continue
+			}
+			matches = append(matches, record)
+		}
+		return nil
+	})
+	return matches
+}
+
+// Clear drops every record
+func (s *Store) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		return fmt.Errorf("history store is closed")
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{recordsBucket, appsBucket} {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clear history: %w", err)
+	}
+	s.count = 0
+	return nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Close()
+	s.db = nil
+	return err
+}
+
+func matchesText(record Record, words []string) bool {
+	text := strings.ToLower(record.Summary + "\n" + record.Body)
+	for _, word := range words {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// recordKey orders records by closing time, seq keeps keys unique
+func recordKey(closed time.Time, seq uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, uint64(max(closed.UnixNano(), 0)))
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
+
+// appKey names an app's index bucket, app names match ignoring case
+func appKey(appName string) []byte {
+	return []byte(strings.ToLower(appName))
+}
+
+// applyRetention drops the oldest records beyond the configured age and
+// count
+// Caller must hold the lock
+func (s *Store) applyRetention(tx *bolt.Tx, now time.Time) error {
+	var cutoff []byte
+	if s.retention.MaxAge > 0 {
+		cutoff = recordKey(now.Add(-s.retention.MaxAge), 0)
+	}
+	excess := 0
+	if s.retention.MaxEntries > 0 {
+		excess = s.count - s.retention.MaxEntries
+	}
+
+	records := tx.Bucket(recordsBucket)
+	apps := tx.Bucket(appsBucket)
+
+	// Deleting under a cursor skips keys, collect them first
+	var drop [][]byte
+	cursor := records.Cursor()
+	for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
+		if len(drop) >= excess && (cutoff == nil || bytes.Compare(key, cutoff) >= 0) {
+			break
+		}
+		drop = append(drop, key)
+	}
+
+	for _, key := range drop {
+		var record Record
+		if err := json.Unmarshal(records.Get(key), &record); err == nil {
+			if index := apps.Bucket(appKey(record.AppName)); index != nil {
+				if err := index.Delete(key); err != nil {
+					return err
+				}
+			}
+		}
+		if err := records.Delete(key); err != nil {
+			return err
+		}
+	}
+	s.count -= len(drop)
+	return nil
+}
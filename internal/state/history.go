@@ -0,0 +1,88 @@
+package state
+
+import (
+	"time"
+)
+
+// HistoryEntry is a notification that has left the screen
+type HistoryEntry struct {
+	Notification Notification
+	ClosedAt     time.Time
+	Reason       NotificationCloseReason
+	Unseen       bool // Never made it on screen, e.g. muted or filtered
+}
+
+// addHistory records a notification taken off screen
+// Caller must hold the lock
+func (ns *NotificationState) addHistory(notification Notification, reason NotificationCloseReason) {
+	ns.addHistoryEntry(HistoryEntry{
+		Notification: notification,
+		ClosedAt:     time.Now(),
+		Reason:       reason,
+	})
+}
+
+// addHistoryEntry hands an entry to OnHistory and appends it to the history
+// ring buffer, dropping the oldest entries beyond the configured size
+// Caller must hold the lock
+func (ns *NotificationState) addHistoryEntry(entry HistoryEntry) {
+	if ns.OnHistory != nil {
+		ns.OnHistory(entry)
+	}
+
+	size := int(ns.Config.HistorySize)
+	if size == 0 {
+		return
+	}
+
+	ns.History = append(ns.History, entry)
+
+	if overflow := len(ns.History) - size; overflow > 0 {
+		ns.History = ns.History[overflow:]
+	}
+}
+
+// AddHistory records a notification that never made it on screen
+func (ns *NotificationState) AddHistory(notification Notification, reason NotificationCloseReason) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	ns.addHistoryEntry(HistoryEntry{
+		Notification: notification,
+		ClosedAt:     time.Now(),
+		Reason:       reason,
+		Unseen:       true,
+	})
+}
+
+// GetHistory returns the history, oldest first
+func (ns *NotificationState) GetHistory() []HistoryEntry {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	history := make([]HistoryEntry, len(ns.History))
+	copy(history, ns.History)
+	return history
+}
+
+// ClearHistory forgets all closed notifications
+func (ns *NotificationState) ClearHistory() {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	ns.History = nil
+}
+
+// PopHistory removes and returns the most recently closed notification
+func (ns *NotificationState) PopHistory() (HistoryEntry, bool) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if len(ns.History) == 0 {
+		return HistoryEntry{}, false
+	}
+
+	last := ns.History[len(ns.History)-1]
+	ns.History = ns.History[:len(ns.History)-1]
+	return last, true
+}
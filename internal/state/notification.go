@@ -5,17 +5,44 @@ import (
 )
 
 type Notification struct {
-	Id         uint32         `toml:"id"`
-	Timeout    uint32         `toml:"timeout"`
-	Timestamp  time.Time      `toml:"timestamp"`
-	NotifyType *string        `toml:"notify_type, omitempty"`
-	AppName    string         `toml:"app_name"`
-	AppIcon    string         `toml:"app_icon"`
-	Summary    string         `toml:"summary"`
-	Body       string         `toml:"body"`
-	Hints      map[string]any `toml:"hints"`
-	Actions    []string       `toml:"actions"`
-	Widget     *string        `toml:"widget, omitempty"`
+	Id          uint32         `toml:"id"`
+	Timeout     time.Duration  `toml:"timeout"`
+	Timestamp   time.Time      `toml:"timestamp"`
+	Created     time.Time      `toml:"created"` // Arrival, Timestamp moves with pauses and extensions
+	NotifyType  *string        `toml:"notify_type, omitempty"`
+	AppName     string         `toml:"app_name"`
+	AppIcon     string         `toml:"app_icon"`
+	Summary     string         `toml:"summary"`
+	Body        string         `toml:"body"`
+	Hints       map[string]any `toml:"hints"`
+	Actions     []string       `toml:"actions"`
+	Widget      *string        `toml:"widget, omitempty"`
+	ExtraClass  *string        `toml:"extra_class, omitempty"`
+	Compact     bool           `toml:"compact"`
+	Slot        int            `toml:"slot"`
+	Read        bool           `toml:"read"`
+	Monitor     string         `toml:"monitor"`
+	Paused      bool           `toml:"paused"`
+	PausedAt    time.Time      `toml:"paused_at"`
+	Image       string         `toml:"image"`
+	DisplayName string         `toml:"display_name"`
+	Links       []Link         `toml:"links"`
+	Sender      string         `toml:"sender"` // Unique D-Bus name, empty when posted by the daemon
+	Count       int            `toml:"count"`  // Identical notifications coalesced into this one
+	StackTag    string         `toml:"stack_tag"`
+	Pinned      bool           `toml:"pinned"`  // Stays until closed, exempt from timeouts and eviction
+	Closing     bool           `toml:"closing"` // Closed, still shown while its exit animation plays
+}
+
+// Copies returns how many identical notifications this one stands for
+func (n Notification) Copies() int {
+	return max(1, n.Count)
+}
+
+// Link is a URL found in the body, either an <a href> or a bare URL
+type Link struct {
+	URL  string `toml:"url" json:"url"`
+	Text string `toml:"text" json:"text"`
 }
 
 type LifetimeType string
@@ -30,33 +57,47 @@ type Lifetime struct {
 	Value uint32
 }
 
-type NotificationCloseReason int
+// NotificationCloseReason is why a notification left the screen, its values
+// are the reason codes of the NotificationClosed signal
+type NotificationCloseReason uint32
 
 const (
-	Expired NotificationCloseReason = iota
-	Dismiss
-	CloseNotification
-	Other
+	Expired           NotificationCloseReason = 1
+	Dismiss           NotificationCloseReason = 2
+	CloseNotification NotificationCloseReason = 3
+	Undefined         NotificationCloseReason = 4
 )
 
-func (r NotificationCloseReason) String() string {
-	switch r {
-	case Expired:
-		return "expired"
-	case Dismiss:
-		return "dismiss"
-	case CloseNotification:
-		return "close_notification"
-	case Other:
-		return "other"
-	default:
-		return "unknown"
+// closeReasonNames maps each reason to the name used in events and history
+var closeReasonNames = map[NotificationCloseReason]string{
+	Expired:           "expired",
+	Dismiss:           "dismiss",
+	CloseNotification: "close_notification",
+	Undefined:         "undefined",
+}
+
+// CloseReasonFromCode converts a NotificationClosed reason code, codes the
+// spec does not define are Undefined
+func CloseReasonFromCode(code uint32) NotificationCloseReason {
+	reason := NotificationCloseReason(code)
+	if _, ok := closeReasonNames[reason]; !ok {
+		return Undefined
 	}
+	return reason
+}
+
+// Code returns the reason code sent with the NotificationClosed signal
+func (r NotificationCloseReason) Code() uint32 {
+	return uint32(CloseReasonFromCode(uint32(r)))
+}
+
+func (r NotificationCloseReason) String() string {
+	return closeReasonNames[CloseReasonFromCode(uint32(r))]
 }
 
 func (n *Notification) GetLifetime() Lifetime {
 	if n.Timeout != 0 {
-		timeoutAt := uint32(n.Timestamp.Unix()) + n.Timeout
+		timeoutAt := uint32(n.Timestamp.Add(n.Timeout).Unix())
 		return Lifetime{
 			Type:  Timeout,
 			Value: timeoutAt,
@@ -69,10 +110,60 @@ func (n *Notification) GetLifetime() Lifetime {
 	}
 }
 
+// AppDisplayName returns the name to show for the sending app
+func (n *Notification) AppDisplayName() string {
+	if n.DisplayName != "" {
+		return n.DisplayName
+	}
+	return n.AppName
+}
+
 func (n *Notification) IsExpired() bool {
-	if n.Timeout == 0 {
+	if n.Timeout == 0 || n.Pinned {
 		return false
 	}
-	expiresAt := n.Timestamp.Add(time.Duration(n.Timeout) * time.Second)
-	return time.Now().After(expiresAt)
+	return n.age(time.Time{}) >= n.Timeout
+}
+
+// age returns how long the notification has been counting down, a paused
+// notification stops aging. pausedAt is when every notification was paused,
+// zero while they run.
+func (n *Notification) age(pausedAt time.Time) time.Duration {
+	if n.Paused {
+		return n.PausedAt.Sub(n.Timestamp)
+	}
+	if !pausedAt.IsZero() {
+		// Notifications arriving during the pause haven't aged at all
+		return max(0, pausedAt.Sub(n.Timestamp))
+	}
+	return time.Since(n.Timestamp)
+}
+
+// CreatedAt returns when the notification arrived
+func (n *Notification) CreatedAt() time.Time {
+	if n.Created.IsZero() {
+		return n.Timestamp
+	}
+	return n.Created
+}
+
+// ExpiresAt returns when the notification times out, false while it is not
+// counting down because it is persistent, pinned or paused, on its own or
+// with all others since pausedAt
+func (n *Notification) ExpiresAt(pausedAt time.Time) (time.Time, bool) {
+	if n.Timeout == 0 || n.Pinned || n.Paused || !pausedAt.IsZero() {
+		return time.Time{}, false
+	}
+	return n.Timestamp.Add(n.Timeout), true
+}
+
+// TimeLeftFraction returns the share of the timeout still remaining, from 1
+// when the notification arrives down to 0 when it expires, frozen while
+// paused, see age. Persistent and pinned notifications always report 1.
+func (n *Notification) TimeLeftFraction(pausedAt time.Time) float64 {
+	if n.Timeout == 0 || n.Pinned {
+		return 1
+	}
+	left := n.Timeout - n.age(pausedAt)
+	return max(0, min(1, float64(left)/float64(n.Timeout)))
 }
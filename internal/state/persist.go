@@ -0,0 +1,86 @@
+package state
+
+import (
+	"time"
+)
+
+// Snapshot is the part of the state that survives a daemon restart
+type Snapshot struct {
+	IdCounter     uint32              `json:"id_counter"`
+	Notifications []SavedNotification `json:"notifications"`
+	DndQueue      []Notification      `json:"dnd_queue"`
+	Dnd           bool                `json:"dnd"`
+}
+
+// SavedNotification is an active notification with how long it had been
+// counting down, timestamps mean nothing to the next daemon
+type SavedNotification struct {
+	Notification
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// Snapshot captures the active notifications, the ID counter and
+// Do-Not-Disturb with its queue
+func (ns *NotificationState) Snapshot() Snapshot {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	now := time.Now()
+	snapshot := Snapshot{
+		IdCounter:     ns.IdCounter,
+		Notifications: make([]SavedNotification, 0, len(ns.Notifications)),
+		DndQueue:      append([]Notification(nil), ns.DndQueue...),
+		Dnd:           ns.Dnd,
+	}
+
+	for _, notification := range ns.Notifications {
+		// Paused notifications stopped aging when they were paused
+		frozenAt := now
+		if notification.Paused && notification.PausedAt.Before(frozenAt) {
+			frozenAt = notification.PausedAt
+		}
+		if ns.Paused && ns.PausedAt.Before(frozenAt) {
+			frozenAt = ns.PausedAt
+		}
+
+		snapshot.Notifications = append(snapshot.Notifications, SavedNotification{
+			Notification: notification,
+			Elapsed:      max(0, frozenAt.Sub(notification.Timestamp)),
+		})
+	}
+
+	return snapshot
+}
+
+// Restore brings back a snapshot taken by a previous daemon and returns the
+// restored notifications, whose timeouts the caller has to re-arm
+func (ns *NotificationState) Restore(snapshot Snapshot) []Notification {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	now := time.Now()
+	ns.IdCounter = max(ns.IdCounter, snapshot.IdCounter)
+	ns.Dnd = ns.Dnd || snapshot.Dnd
+	ns.DndQueue = append(ns.DndQueue, snapshot.DndQueue...)
+
+	restored := make([]Notification, 0, len(snapshot.Notifications))
+	for _, saved := range snapshot.Notifications {
+		if ns.findIndexById(saved.Id) >= 0 {
+			continue
+		}
+
+		notification := saved.Notification
+		notification.Timestamp = now.Add(-saved.Elapsed)
+		if notification.Paused {
+			notification.PausedAt = now
+		}
+		if notification.IsExpired() {
+			continue
+		}
+
+		ns.Notifications = append(ns.Notifications, notification)
+		restored = append(restored, notification)
+	}
+
+	return restored
+}
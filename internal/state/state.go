@@ -1,8 +1,10 @@
 package state
 
 import (
+	"fmt"
 	"slices"
 	"sync"
+	"time"
 
 	"github.com/godbus/dbus/v5"
 
@@ -15,6 +17,21 @@ type NotificationState struct {
 	Config        config.Config
 	IdCounter     uint32
 	DbusConn      *dbus.Conn
+	StackCursors  map[string]int
+	Expanded      map[string]bool // App groups expanded in grouped display mode
+	SelectedId    uint32
+	Overrides     map[string]string
+	MutedApps     map[string]bool
+	CenterOpen    bool
+	History       []HistoryEntry
+	Dnd           bool
+	ScheduledDnd  bool // Quiet hours from the config are active
+	DndQueue      []Notification
+	Paused        bool
+	PausedAt      time.Time
+	// OnHistory sees every closed notification, whatever the history size,
+	// it is called with the lock held and must not call back into the state
+	OnHistory func(HistoryEntry)
 }
 
 func NewNotificationState(cfg config.Config, conn *dbus.Conn) *NotificationState {
@@ -23,45 +40,137 @@ func NewNotificationState(cfg config.Config, conn *dbus.Conn) *NotificationState
 		Config:        cfg,
 		IdCounter:     0,
 		DbusConn:      conn,
+		StackCursors:  make(map[string]int),
+		Expanded:      make(map[string]bool),
+		Overrides:     make(map[string]string),
+		MutedApps:     make(map[string]bool),
 	}
 }
 
+// NextId allocates the ID of a new notification. The counter wraps around
+// skipping 0, which the spec reserves, and IDs that are still on screen,
+// held back by Do-Not-Disturb or in the history.
 func (ns *NotificationState) NextId() uint32 {
 	ns.mu.Lock()
 	defer ns.mu.Unlock()
 
-	ns.IdCounter++
-	return ns.IdCounter
+	for {
+		ns.IdCounter++
+		if ns.IdCounter != 0 && !ns.idInUse(ns.IdCounter) {
+			return ns.IdCounter
+		}
+	}
+}
+
+// idInUse reports whether any notification still refers to id
+// Caller must hold the lock
+func (ns *NotificationState) idInUse(id uint32) bool {
+	if ns.isLive(id) {
+		return true
+	}
+	return slices.ContainsFunc(ns.History, func(entry HistoryEntry) bool {
+		return entry.Notification.Id == id
+	})
+}
+
+// isLive reports whether id is on screen or held back by Do-Not-Disturb
+// Caller must hold the lock
+func (ns *NotificationState) isLive(id uint32) bool {
+	match := func(n Notification) bool { return n.Id == id }
+	return slices.ContainsFunc(ns.Notifications, match) || slices.ContainsFunc(ns.DndQueue, match)
+}
+
+// IsLive reports whether a client may still replace notification id
+func (ns *NotificationState) IsLive(id uint32) bool {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	return ns.isLive(id)
 }
 
 func (ns *NotificationState) AddNotification(notification Notification) {
 	ns.mu.Lock()
 	defer ns.mu.Unlock()
 
+	// New content always brings the app's stack back to its newest entry
+	delete(ns.StackCursors, notification.AppName)
+	// A group that emptied starts out collapsed again
+	if ns.countByApp(notification.AppName) == 0 {
+		delete(ns.Expanded, notification.AppName)
+	}
+
 	for i, existing := range ns.Notifications {
 		if existing.Id == notification.Id {
+			// Replacements keep their place on screen and their pin
+			notification.Slot = existing.Slot
+			notification.Pinned = existing.Pinned
 			ns.Notifications[i] = notification
 			return
 		}
 	}
 
+	// A chatty app makes room among its own notifications first
+	maxPerApp := int(ns.Config.MaxNotificationsPerApp)
+	if maxPerApp > 0 && ns.countByApp(notification.AppName) >= maxPerApp {
+		oldestIdx := ns.findOldestNotificationIndexByApp(notification.AppName)
+		if oldestIdx >= 0 {
+			ns.addHistory(ns.Notifications[oldestIdx], Undefined)
+			ns.removeNotificationByIndex(oldestIdx)
+		}
+	}
+
 	maxNotifications := int(ns.Config.MaxNotifications)
 	if maxNotifications > 0 && len(ns.Notifications) >= maxNotifications {
 		oldestIdx := ns.findOldestNoticationIndex()
 		if oldestIdx >= 0 {
+			ns.addHistory(ns.Notifications[oldestIdx], Undefined)
 			ns.removeNotificationByIndex(oldestIdx)
 		}
 	}
 
+	notification.Slot = ns.findFreeSlot()
 	ns.Notifications = append(ns.Notifications, notification)
 }
 
-func (ns *NotificationState) RemoveNotification(id uint32) bool {
+// Coalesce folds a notification into an identical one from the same app
+// shown within the window, bumping its count and restarting its timeout.
+// It returns the updated notification, or false if there is no duplicate.
+func (ns *NotificationState) Coalesce(notification Notification, window time.Duration) (Notification, bool) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	now := time.Now()
+	for i := len(ns.Notifications) - 1; i >= 0; i-- {
+		existing := &ns.Notifications[i]
+		if existing.AppName != notification.AppName ||
+			existing.Summary != notification.Summary ||
+			existing.Body != notification.Body ||
+			now.Sub(existing.Timestamp) > window {
+			continue
+		}
+
+		existing.Count = existing.Copies() + 1
+		existing.Timestamp = now
+		existing.Created = now
+		existing.Compact = false
+		existing.Read = false
+		if existing.Paused {
+			existing.PausedAt = now
+		}
+		return *existing, true
+	}
+	return Notification{}, false
+}
+
+// RemoveNotification takes a notification off screen and records it in the
+// history with the reason it was closed
+func (ns *NotificationState) RemoveNotification(id uint32, reason NotificationCloseReason) bool {
 	ns.mu.Lock()
 	defer ns.mu.Unlock()
 
 	for i, notification := range ns.Notifications {
 		if notification.Id == id {
+			ns.addHistory(notification, reason)
 			ns.removeNotificationByIndex(i)
 			return true
 		}
@@ -69,6 +178,22 @@ func (ns *NotificationState) RemoveNotification(id uint32) bool {
 	return false
 }
 
+// ExpireNotification removes a notification whose timeout has run out,
+// reporting false if it is gone or no longer due, for example because it
+// was paused, pinned, extended or replaced after its timer fired
+func (ns *NotificationState) ExpireNotification(id uint32) bool {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	idx := ns.findIndexById(id)
+	if ns.Paused || idx < 0 || !ns.Notifications[idx].IsExpired() {
+		return false
+	}
+	ns.addHistory(ns.Notifications[idx], Expired)
+	ns.removeNotificationByIndex(idx)
+	return true
+}
+
 func (ns *NotificationState) GetNotifications() []Notification {
 	ns.mu.Lock()
 	defer ns.mu.Unlock()
@@ -90,13 +215,469 @@ func (ns *NotificationState) GetNotificationsById(id uint32) (Notification, bool
 	return Notification{}, false
 }
 
+// ExtendTimeout pushes back the expiry of a timed notification and returns
+// the time left until it now expires
+func (ns *NotificationState) ExtendTimeout(id uint32, extra time.Duration) (time.Duration, error) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	idx := ns.findIndexById(id)
+	if idx < 0 {
+		return 0, fmt.Errorf("notification with ID %d not found", id)
+	}
+
+	notification := &ns.Notifications[idx]
+	if notification.Timeout == 0 {
+		return 0, fmt.Errorf("notification %d does not expire", id)
+	}
+
+	notification.Timeout += extra
+	expiresAt := notification.Timestamp.Add(notification.Timeout)
+	return time.Until(expiresAt), nil
+}
+
+// SetCompact marks a notification as compact, returns false if not found
+func (ns *NotificationState) SetCompact(id uint32) bool {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	for i := range ns.Notifications {
+		if ns.Notifications[i].Id == id {
+			ns.Notifications[i].Compact = true
+			return true
+		}
+	}
+	return false
+}
+
+// GetLatest returns the most recently received notification
+func (ns *NotificationState) GetLatest() (Notification, bool) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if len(ns.Notifications) == 0 {
+		return Notification{}, false
+	}
+
+	// Timestamp moves on resume and extend, arrival does not
+	latest := ns.Notifications[0]
+	for _, notification := range ns.Notifications[1:] {
+		if !notification.CreatedAt().Before(latest.CreatedAt()) {
+			latest = notification
+		}
+	}
+	return latest, true
+}
+
+// SelectNext moves the selection cursor to the next notification, wrapping
+// around, and returns the selected ID (0 when there is nothing to select)
+func (ns *NotificationState) SelectNext() uint32 {
+	return ns.moveSelection(1)
+}
+
+// SelectPrev moves the selection cursor to the previous notification
+func (ns *NotificationState) SelectPrev() uint32 {
+	return ns.moveSelection(-1)
+}
+
+// GetSelected returns the selected notification if it is still active
+func (ns *NotificationState) GetSelected() (Notification, bool) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if idx := ns.findIndexById(ns.SelectedId); idx >= 0 {
+		return ns.Notifications[idx], true
+	}
+	return Notification{}, false
+}
+
+// GetSelectedId returns the ID under the selection cursor, 0 if none
+func (ns *NotificationState) GetSelectedId() uint32 {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	return ns.SelectedId
+}
+
+func (ns *NotificationState) moveSelection(step int) uint32 {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	count := len(ns.Notifications)
+	if count == 0 {
+		ns.SelectedId = 0
+		return 0
+	}
+
+	idx := ns.findIndexById(ns.SelectedId)
+	switch {
+	case idx < 0 && step > 0:
+		idx = 0
+	case idx < 0:
+		idx = count - 1
+	default:
+		idx = (idx + step + count) % count
+	}
+
+	ns.SelectedId = ns.Notifications[idx].Id
+	return ns.SelectedId
+}
+
+// CycleStack advances the stack cursor of the given app
+func (ns *NotificationState) CycleStack(appName string) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	ns.StackCursors[appName]++
+}
+
+// GetStackCursor returns how many entries back from the newest the app's
+// stack is currently showing
+func (ns *NotificationState) GetStackCursor(appName string) int {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	return ns.StackCursors[appName]
+}
+
+// SetExpanded expands or collapses the group of the given app
+func (ns *NotificationState) SetExpanded(appName string, expanded bool) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if expanded {
+		ns.Expanded[appName] = true
+	} else {
+		delete(ns.Expanded, appName)
+	}
+}
+
+// IsExpanded reports whether the app's group shows all its notifications
+func (ns *NotificationState) IsExpanded(appName string) bool {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	return ns.Expanded[appName]
+}
+
+// UpdateConfig swaps in a new configuration, settings changed at runtime
+// stay in effect on top of it
 func (ns *NotificationState) UpdateConfig(newConfig config.Config) {
 	ns.mu.Lock()
 	defer ns.mu.Unlock()
 
+	for key, value := range ns.Overrides {
+		if err := newConfig.SetValue(key, value); err != nil {
+			delete(ns.Overrides, key)
+		}
+	}
 	ns.Config = newConfig
 }
 
+// SetCenterOpen records whether the notification center is open, opening
+// it marks every active notification as read
+func (ns *NotificationState) SetCenterOpen(open bool) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	ns.CenterOpen = open
+	if open {
+		for i := range ns.Notifications {
+			ns.Notifications[i].Read = true
+		}
+	}
+}
+
+// IsCenterOpen reports whether the notification center is open
+func (ns *NotificationState) IsCenterOpen() bool {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	return ns.CenterOpen
+}
+
+// SetDnd switches Do-Not-Disturb, reporting whether the state changed.
+// Disabling hands back the notifications queued in the meantime.
+func (ns *NotificationState) SetDnd(enabled bool) ([]Notification, bool) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if ns.Dnd == enabled {
+		return nil, false
+	}
+
+	ns.Dnd = enabled
+	if enabled || ns.ScheduledDnd {
+		return nil, true
+	}
+
+	queued := ns.DndQueue
+	ns.DndQueue = nil
+	return queued, true
+}
+
+// SetScheduledDnd records whether the configured quiet hours are active and
+// otherwise behaves like SetDnd, the queue is only handed back once neither
+// is on
+func (ns *NotificationState) SetScheduledDnd(active bool) ([]Notification, bool) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if ns.ScheduledDnd == active {
+		return nil, false
+	}
+
+	ns.ScheduledDnd = active
+	if active || ns.Dnd {
+		return nil, true
+	}
+
+	queued := ns.DndQueue
+	ns.DndQueue = nil
+	return queued, true
+}
+
+// IsScheduledDnd reports whether the quiet hours are active
+func (ns *NotificationState) IsScheduledDnd() bool {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	return ns.ScheduledDnd
+}
+
+// IsDnd reports whether Do-Not-Disturb is on
+func (ns *NotificationState) IsDnd() bool {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	return ns.Dnd
+}
+
+// QueueForDnd holds a notification back until Do-Not-Disturb ends,
+// replacing a queued notification with the same ID
+func (ns *NotificationState) QueueForDnd(notification Notification) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	for i, queued := range ns.DndQueue {
+		if queued.Id == notification.Id {
+			ns.DndQueue[i] = notification
+			return
+		}
+	}
+	ns.DndQueue = append(ns.DndQueue, notification)
+}
+
+// DndQueueLength returns the number of notifications held back
+func (ns *NotificationState) DndQueueLength() int {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	return len(ns.DndQueue)
+}
+
+// Pause freezes the lifetime of all notifications, returns false if they
+// were already paused
+func (ns *NotificationState) Pause() bool {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if ns.Paused {
+		return false
+	}
+	ns.Paused = true
+	ns.PausedAt = time.Now()
+	return true
+}
+
+// Resume unfreezes notifications, shifting their timestamps by the time
+// they spent paused so their remaining lifetime is preserved
+func (ns *NotificationState) Resume() bool {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if !ns.Paused {
+		return false
+	}
+
+	now := time.Now()
+	for i := range ns.Notifications {
+		notification := &ns.Notifications[i]
+		// Individually paused notifications catch up when they resume
+		if notification.Paused {
+			continue
+		}
+		// Notifications arriving during the pause haven't aged at all
+		frozenSince := notification.Timestamp
+		if frozenSince.Before(ns.PausedAt) {
+			frozenSince = ns.PausedAt
+		}
+		notification.Timestamp = notification.Timestamp.Add(now.Sub(frozenSince))
+	}
+
+	ns.Paused = false
+	return true
+}
+
+// PauseNotification freezes the lifetime of a single notification, returns
+// false if it was already paused
+func (ns *NotificationState) PauseNotification(id uint32) (bool, error) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	idx := ns.findIndexById(id)
+	if idx < 0 {
+		return false, fmt.Errorf("notification with ID %d not found", id)
+	}
+
+	notification := &ns.Notifications[idx]
+	if notification.Paused {
+		return false, nil
+	}
+	notification.Paused = true
+	notification.PausedAt = time.Now()
+	return true, nil
+}
+
+// SetPinned pins or unpins a notification and returns it, reporting whether
+// anything changed. Unpinning restarts the notification's timeout.
+func (ns *NotificationState) SetPinned(id uint32, pinned bool) (Notification, bool, error) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	idx := ns.findIndexById(id)
+	if idx < 0 {
+		return Notification{}, false, fmt.Errorf("notification with ID %d not found", id)
+	}
+
+	notification := &ns.Notifications[idx]
+	if notification.Pinned == pinned {
+		return *notification, false, nil
+	}
+	notification.Pinned = pinned
+	if !pinned {
+		notification.Timestamp = time.Now()
+		if notification.Paused {
+			notification.PausedAt = notification.Timestamp
+		}
+	}
+	return *notification, true, nil
+}
+
+// IsPinned reports whether a notification is pinned
+func (ns *NotificationState) IsPinned(id uint32) bool {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	idx := ns.findIndexById(id)
+	return idx >= 0 && ns.Notifications[idx].Pinned
+}
+
+// ResumeNotification unfreezes a single notification, shifting its
+// timestamp so the remaining lifetime is preserved, and returns it
+func (ns *NotificationState) ResumeNotification(id uint32) (Notification, bool, error) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	idx := ns.findIndexById(id)
+	if idx < 0 {
+		return Notification{}, false, fmt.Errorf("notification with ID %d not found", id)
+	}
+
+	notification := &ns.Notifications[idx]
+	if !notification.Paused {
+		return *notification, false, nil
+	}
+
+	// Time spent under a global pause is made up by Resume instead
+	frozenUntil := time.Now()
+	if ns.Paused && ns.PausedAt.Before(frozenUntil) {
+		frozenUntil = ns.PausedAt
+	}
+	if frozenUntil.After(notification.PausedAt) {
+		notification.Timestamp = notification.Timestamp.Add(frozenUntil.Sub(notification.PausedAt))
+	}
+
+	notification.Paused = false
+	notification.PausedAt = time.Time{}
+	return *notification, true, nil
+}
+
+// IsPaused reports whether notification lifetimes are frozen
+func (ns *NotificationState) IsPaused() bool {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	return ns.Paused
+}
+
+// GetPausedAt returns when all notifications were paused, zero while they
+// run
+func (ns *NotificationState) GetPausedAt() time.Time {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if !ns.Paused {
+		return time.Time{}
+	}
+	return ns.PausedAt
+}
+
+// SetMuted mutes or unmutes an app for the rest of the session
+func (ns *NotificationState) SetMuted(appName string, muted bool) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if muted {
+		ns.MutedApps[appName] = true
+	} else {
+		delete(ns.MutedApps, appName)
+	}
+}
+
+// IsMuted reports whether popups from an app are suppressed
+func (ns *NotificationState) IsMuted(appName string) bool {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	return ns.MutedApps[appName]
+}
+
+// GetMutedApps returns the muted app names, sorted
+func (ns *NotificationState) GetMutedApps() []string {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	apps := make([]string, 0, len(ns.MutedApps))
+	for app := range ns.MutedApps {
+		apps = append(apps, app)
+	}
+	slices.Sort(apps)
+	return apps
+}
+
+// SetConfigValue changes one setting for the rest of the session and
+// remembers it as an override of the config file
+func (ns *NotificationState) SetConfigValue(key, value string) error {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if err := ns.Config.SetValue(key, value); err != nil {
+		return err
+	}
+	ns.Overrides[key] = value
+	return nil
+}
+
+// IsOverridden reports whether a setting was changed at runtime
+func (ns *NotificationState) IsOverridden(key string) bool {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	_, ok := ns.Overrides[key]
+	return ok
+}
+
 func (ns *NotificationState) GetConfig() config.Config {
 	ns.mu.Lock()
 	defer ns.mu.Unlock()
@@ -113,26 +694,107 @@ func (ns *NotificationState) removeNotificationByIndex(index int) {
 	}
 }
 
+// findIndexById returns the index of the notification with the given ID
+// Returns -1 if it does not exist
+// Caller must hold the lock
+func (ns *NotificationState) findIndexById(id uint32) int {
+	for i, notification := range ns.Notifications {
+		if notification.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// findFreeSlot returns the lowest display slot not taken by a notification
+// Caller must hold the lock
+func (ns *NotificationState) findFreeSlot() int {
+	taken := make(map[int]bool, len(ns.Notifications))
+	for _, notification := range ns.Notifications {
+		taken[notification.Slot] = true
+	}
+
+	slot := 0
+	for taken[slot] {
+		slot++
+	}
+	return slot
+}
+
 // findOldestNotificationIndex finds the index of the oldest notification
-// Returns -1 if no notifications exist
+// that is not pinned
+// Returns -1 if no such notification exists
 // Caller must hold the lock
 func (ns *NotificationState) findOldestNoticationIndex() int {
-	if len(ns.Notifications) == 0 {
-		return -1
+	oldestIdx := -1
+	for i, notification := range ns.Notifications {
+		if notification.Pinned {
+			continue
+		}
+		if oldestIdx < 0 || notification.Timestamp.Before(ns.Notifications[oldestIdx].Timestamp) {
+			oldestIdx = i
+		}
 	}
-	oldestIdx := 0
-	oldestTime := ns.Notifications[0].Timestamp
 
-	for i, notification := range ns.Notifications[1:] {
-		if notification.Timestamp.Before(oldestTime) {
-			oldestIdx = i + 1
-			oldestTime = notification.Timestamp
+	return oldestIdx
+}
+
+// FindByAppHint returns the id of the on-screen notification from appName
+// that carries the hint key, if any
+func (ns *NotificationState) FindByAppHint(appName, key string) (uint32, bool) {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	for _, notification := range ns.Notifications {
+		if notification.AppName != appName {
+			continue
+		}
+		if _, ok := notification.Hints[key]; ok {
+			return notification.Id, true
 		}
 	}
+	return 0, false
+}
+
+// FindByStackTag returns the notification of appName carrying the given
+// stack tag, see dbus.GetStackTag
+func (ns *NotificationState) FindByStackTag(appName, tag string) (uint32, bool) {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	for _, notification := range ns.Notifications {
+		if notification.AppName == appName && notification.StackTag == tag {
+			return notification.Id, true
+		}
+	}
+	return 0, false
+}
 
+// findOldestNotificationIndexByApp returns the index of the oldest
+// notification from appName that is not pinned, -1 if it has none on screen
+func (ns *NotificationState) findOldestNotificationIndexByApp(appName string) int {
+	oldestIdx := -1
+	for i, notification := range ns.Notifications {
+		if notification.AppName != appName || notification.Pinned {
+			continue
+		}
+		if oldestIdx < 0 || notification.Timestamp.Before(ns.Notifications[oldestIdx].Timestamp) {
+			oldestIdx = i
+		}
+	}
 	return oldestIdx
 }
 
+func (ns *NotificationState) countByApp(appName string) int {
+	count := 0
+	for _, notification := range ns.Notifications {
+		if notification.AppName == appName {
+			count++
+		}
+	}
+	return count
+}
+
 // CleanupExpiredNotifications removes all expired notifications
 func (ns *NotificationState) CleanupExpiredNotifications() []uint32 {
 	ns.mu.Lock()
@@ -144,6 +806,7 @@ func (ns *NotificationState) CleanupExpiredNotifications() []uint32 {
 	for _, notification := range ns.Notifications {
 		if notification.IsExpired() {
 			expiredIds = append(expiredIds, notification.Id)
+			ns.addHistory(notification, Expired)
 		} else {
 			remainingNotifications = append(remainingNotifications, notification)
 		}
@@ -1,27 +1,106 @@
 package constants
 
-import "path/filepath"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
 
 const (
-	// IPC socket path for daemon communication
+	// IPC socket for daemon communication, relative to $XDG_RUNTIME_DIR
+	IPCSocketName = "end/ipc.sock"
+
+	// IPC socket path used when XDG_RUNTIME_DIR is not set
 	IPCSocketPath = "/tmp/eww-socket"
 
-	// Image temp directory for notification images
-	ImageTempDir = "/tmp/end-images"
+	// Environment variable overriding the IPC socket path
+	SocketEnvVar = "END_SOCKET"
+
+	// Environment variable overriding the config file path
+	ConfigEnvVar = "END_CONFIG"
+
+	// Environment variable set on the background process started by -daemon
+	DaemonizedEnvVar = "END_DAEMONIZED"
+
+	// Directory for notification images, relative to $XDG_RUNTIME_DIR
+	ImageDirName = "end/images"
 
 	// Application info
 	AppName     = "eww-notification-daemon"
 	AppVendor   = "eww"
 	AppVersion  = "1.2.0"
 	SpecVersion = "1.2"
+
+	// IPCProtocolVersion must be bumped whenever the IPC command syntax
+	// changes in a way older clients would misparse
+	IPCProtocolVersion = 2
 )
 
-// GetImageTempDir returns the full path to the image temp directory
-func GetImageTempDir() string {
-	return ImageTempDir
+// GetSocketPath returns the IPC socket path for a named daemon instance,
+// $XDG_RUNTIME_DIR/end/ipc[-instance].sock or IPCSocketPath[-instance] when
+// there is no runtime directory
+func GetSocketPath(instance string) string {
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		if instance == "" {
+			return IPCSocketPath
+		}
+		return IPCSocketPath + "-" + instance
+	}
+
+	path := filepath.Join(runtimeDir, IPCSocketName)
+	if instance == "" {
+		return path
+	}
+	return strings.TrimSuffix(path, ".sock") + "-" + instance + ".sock"
+}
+
+// GetStatePath returns the file a named daemon instance keeps its state in
+// across restarts, $XDG_STATE_HOME/end/state[-instance].json
+func GetStatePath(instance string) string {
+	return stateFile("state", instance, ".json")
 }
 
-// GetImagePath returns a full path for an image file
-func GetImagePath(filename string) string {
-	return filepath.Join(ImageTempDir, filename)
+// GetHistoryPath returns the persistent history of a named daemon instance,
+// $XDG_STATE_HOME/end/history[-instance].db
+func GetHistoryPath(instance string) string {
+	return stateFile("history", instance, ".db")
+}
+
+// GetLogPath returns the default log file of a named daemon instance,
+// $XDG_STATE_HOME/end/end[-instance].log
+func GetLogPath(instance string) string {
+	return stateFile("end", instance, ".log")
+}
+
+func stateFile(name, instance, ext string) string {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			home = os.TempDir()
+		}
+		stateHome = filepath.Join(home, ".local", "state")
+	}
+
+	if instance != "" {
+		name += "-" + instance
+	}
+	return filepath.Join(stateHome, "end", name+ext)
+}
+
+// GetImageDir returns the directory a named daemon instance saves images
+// in, $XDG_RUNTIME_DIR/end/images[-instance] or a per-user directory under
+// the temp dir when there is no runtime directory
+func GetImageDir(instance string) string {
+	dir := filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), ImageDirName)
+	if os.Getenv("XDG_RUNTIME_DIR") == "" {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("end-%d", os.Getuid()), "images")
+	}
+
+	if instance != "" {
+		dir += "-" + instance
+	}
+	return dir
 }
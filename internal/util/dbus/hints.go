@@ -0,0 +1,183 @@
+package dbus
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// hintKind is the canonical Go type a well known hint is stored as
+type hintKind int
+
+const (
+	kindString hintKind = iota
+	kindBool
+	kindInt32
+	kindUrgency
+)
+
+// hintKinds lists the hints whose encoding varies between clients, hints
+// not listed here are kept as sent
+var hintKinds = map[string]hintKind{
+	HintKeyUrgency:          kindUrgency,
+	HintKeyValue:            kindInt32,
+	HintKeyResident:         kindBool,
+	HintKeySuppressSound:    kindBool,
+	"transient":             kindBool,
+	"action-icons":          kindBool,
+	"x":                     kindInt32,
+	"y":                     kindInt32,
+	HintKeyNotifyType:       kindString,
+	HintKeyClass:            kindString,
+	HintKeyDesktopEntry:     kindString,
+	HintKeyCategory:         kindString,
+	HintKeySoundFile:        kindString,
+	HintKeySoundName:        kindString,
+	HintKeyReplyPlaceholder: kindString,
+	HintKeyReplySubmitLabel: kindString,
+	HintKeyStackTag:         kindString,
+	HintKeySynchronous:      kindString,
+	"image-path":            kindString,
+	"image_path":            kindString,
+}
+
+// NormalizeHints coerces the well known hints into one type each, urgency
+// to uint8, numbers to int32 and flags to bool, whatever encoding the
+// client used. Hints that cannot be read are dropped, so a malformed
+// urgency falls back to normal instead of being misread later.
+func NormalizeHints(hints Hints) Hints {
+	normalized := make(Hints, len(hints))
+	for key, value := range hints {
+		value = unwrapVariant(value)
+
+		kind, known := hintKinds[key]
+		if !known {
+			normalized[key] = value
+			continue
+		}
+
+		var ok bool
+		switch kind {
+		case kindString:
+			value, ok = coerceString(value)
+		case kindBool:
+			value, ok = coerceBool(value)
+		case kindInt32:
+			var n int64
+			if n, ok = coerceInt(value); ok {
+				value = int32(max(-1<<31, min(1<<31-1, n)))
+			}
+		case kindUrgency:
+			value, ok = coerceUrgency(value)
+		}
+		if ok {
+			normalized[key] = value
+		}
+	}
+	return normalized
+}
+
+// unwrapVariant removes variants nested inside the hint's variant
+func unwrapVariant(value any) any {
+	for {
+		variant, ok := value.(interface{ Value() any })
+		if !ok {
+			return value
+		}
+		value = variant.Value()
+	}
+}
+
+func coerceString(value any) (string, bool) {
+	switch val := value.(type) {
+	case string:
+		return val, true
+	case []byte:
+		return string(val), true
+	}
+	// Object paths and other named string types
+	if v := reflect.ValueOf(value); v.Kind() == reflect.String {
+		return v.String(), true
+	}
+	return "", false
+}
+
+// coerceInt reads any integer type, floats and numeric strings
+func coerceInt(value any) (int64, bool) {
+	switch val := value.(type) {
+	case uint8:
+		return int64(val), true
+	case int16:
+		return int64(val), true
+	case uint16:
+		return int64(val), true
+	case int32:
+		return int64(val), true
+	case uint32:
+		return int64(val), true
+	case int64:
+		return val, true
+	case uint64:
+		return int64(min(val, 1<<63-1)), true
+	case int:
+		return int64(val), true
+	case float64:
+		return int64(val), true
+	case bool:
+		if val {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// coerceBool accepts booleans, integers (non-zero is true) and the usual
+// spellings of true and false
+func coerceBool(value any) (bool, bool) {
+	switch val := value.(type) {
+	case bool:
+		return val, true
+	case string:
+		switch strings.ToLower(strings.TrimSpace(val)) {
+		case "true", "yes", "on", "1":
+			return true, true
+		case "false", "no", "off", "0":
+			return false, true
+		}
+		return false, false
+	}
+	if n, ok := coerceInt(value); ok {
+		return n != 0, true
+	}
+	return false, false
+}
+
+// coerceUrgency accepts the spec's byte as any integer type, the urgency
+// names and booleans, where true means critical
+func coerceUrgency(value any) (uint8, bool) {
+	switch val := value.(type) {
+	case bool:
+		if val {
+			return 2, true
+		}
+		return 1, true
+	case string:
+		switch strings.ToLower(strings.TrimSpace(val)) {
+		case "low":
+			return 0, true
+		case "normal":
+			return 1, true
+		case "critical":
+			return 2, true
+		}
+	}
+	n, ok := coerceInt(value)
+	if !ok {
+		return 0, false
+	}
+	return uint8(max(0, min(2, n))), true
+}
@@ -16,39 +16,114 @@ type ImageData struct {
 const (
 	HintKeyNotifyType = "end-type"
 	HintKeyUrgency    = "urgency"
+	HintKeyClass      = "end-class"
+	HintKeyResident   = "resident"
+	HintKeyValue      = "value"
+
+	HintKeyDesktopEntry  = "desktop-entry"
+	HintKeyCategory      = "category"
+	HintKeySoundFile     = "sound-file"
+	HintKeySoundName     = "sound-name"
+	HintKeySuppressSound = "suppress-sound"
+
+	HintKeyReplyPlaceholder = "x-kde-reply-placeholder-text"
+	HintKeyReplySubmitLabel = "x-kde-reply-submit-button-text"
+
+	HintKeyStackTag    = "x-dunst-stack-tag"
+	HintKeySynchronous = "x-canonical-private-synchronous"
 )
 
-func GetStringHint(hints Hints, key string) (string, bool) {
-	if val, exists := hints[key]; exists {
-		if str, ok := val.(string); ok {
-			return str, true
+// GetStackTag returns the tag shared by notifications that replace each
+// other, dunst's stack tag or notify-osd's synchronous hint
+func GetStackTag(hints Hints) (string, bool) {
+	for _, key := range []string{HintKeyStackTag, HintKeySynchronous} {
+		if tag, ok := GetStringHint(hints, key); ok && tag != "" {
+			return tag, true
 		}
 	}
+	return "", false
+}
 
+func GetStringHint(hints Hints, key string) (string, bool) {
+	if val, exists := hints[key]; exists {
+		return coerceString(unwrapVariant(val))
+	}
 	return "", false
 }
 
 func GetByteHint(hints Hints, key string) (uint8, bool) {
 	if val, exists := hints[key]; exists {
-		if b, ok := val.(uint8); ok {
-			return b, true
+		if n, ok := coerceInt(unwrapVariant(val)); ok && n >= 0 && n <= 255 {
+			return uint8(n), true
 		}
 	}
 	return 0, false
 }
 
-func GetImageDataHint(hints Hints, key string) (*ImageData, bool) {
+// GetIntHint reads an integer hint whatever integer type the client sent
+func GetIntHint(hints Hints, key string) (int64, bool) {
+	if val, exists := hints[key]; exists {
+		return coerceInt(unwrapVariant(val))
+	}
+	return 0, false
+}
+
+// GetProgress returns the value hint clamped to 0-100
+func GetProgress(hints Hints) (int, bool) {
+	value, ok := GetIntHint(hints, HintKeyValue)
+	if !ok {
+		return 0, false
+	}
+	return int(max(0, min(100, value))), true
+}
+
+func GetBoolHint(hints Hints, key string) (bool, bool) {
 	if val, exists := hints[key]; exists {
-		if imgData, ok := val.(*ImageData); ok {
-			return imgData, true
+		return coerceBool(unwrapVariant(val))
+	}
+	return false, false
+}
+
+// ImageDataHintKeys are the raw image hints, the deprecated spellings last
+var ImageDataHintKeys = []string{"image-data", "image_data", "icon_data"}
+
+func GetImageDataHint(hints Hints, key string) (*ImageData, bool) {
+	switch val := hints[key].(type) {
+	case *ImageData:
+		return val, true
+	case []any:
+		// D-Bus structs arrive as their fields in order, (iiibiiay)
+		if len(val) != 7 {
+			return nil, false
+		}
+		width, ok1 := val[0].(int32)
+		height, ok2 := val[1].(int32)
+		stride, ok3 := val[2].(int32)
+		hasAlpha, ok4 := val[3].(bool)
+		bitsPerSample, ok5 := val[4].(int32)
+		channels, ok6 := val[5].(int32)
+		pixels, ok7 := val[6].([]byte)
+		if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 || !ok6 || !ok7 {
+			return nil, false
 		}
+		return &ImageData{
+			Width:         width,
+			Height:        height,
+			Stride:        stride,
+			HasAlpha:      hasAlpha,
+			BitsPerSample: bitsPerSample,
+			Channels:      channels,
+			PixelData:     pixels,
+		}, true
 	}
 	return nil, false
 }
 
 func GetUrgency(hints Hints) uint8 {
-	if urgency, ok := GetByteHint(hints, HintKeyUrgency); ok {
-		return urgency
+	if val, exists := hints[HintKeyUrgency]; exists {
+		if urgency, ok := coerceUrgency(unwrapVariant(val)); ok {
+			return urgency
+		}
 	}
 	return 1
 }
@@ -0,0 +1,127 @@
+package logfile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+)
+
+// File is an append-only log file that can be reopened in place, so
+// logrotate can move it away and the daemon continues with a fresh file.
+// With SetRotation it also rotates itself once it grows too large.
+type File struct {
+	mu         sync.Mutex
+	path       string
+	file       *os.File
+	size       int64
+	maxSize    int64
+	maxBackups int
+}
+
+// Open opens (creating if needed) the log file at path
+func Open(path string) (*File, error) {
+	f := &File{path: path}
+	if err := f.Reopen(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// SetRotation rotates the file once a write would take it past maxSize
+// bytes, keeping maxBackups old files as path.1 (newest) onwards. A maxSize
+// of 0 turns rotation off.
+func (f *File) SetRotation(maxSize int64, maxBackups int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.maxSize = maxSize
+	f.maxBackups = maxBackups
+}
+
+// Reopen closes the current file handle and opens the path again
+func (f *File) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.open()
+}
+
+// open opens the path, the caller must hold the lock
+func (f *File) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	f.size = 0
+	if info, err := file.Stat(); err == nil {
+		f.size = info.Size()
+	}
+
+	// Fatal panics bypass the logger, have the runtime write them here too
+	if err := debug.SetCrashOutput(file, debug.CrashOptions{}); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to send crash output to the log file: %v\n", err)
+	}
+
+	if f.file != nil {
+		f.file.Close()
+	}
+	f.file = file
+	return nil
+}
+
+// rotate shifts path to path.1, path.1 to path.2 and so on, dropping the
+// oldest, then starts a fresh file. The caller must hold the lock.
+func (f *File) rotate() error {
+	backup := func(n int) string { return fmt.Sprintf("%s.%d", f.path, n) }
+
+	if f.maxBackups == 0 {
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return f.open()
+	}
+
+	if err := os.Remove(backup(f.maxBackups)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for n := f.maxBackups - 1; n >= 1; n-- {
+		if err := os.Rename(backup(n), backup(n+1)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(f.path, backup(1)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return f.open()
+}
+
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to rotate log file: %v\n", err)
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Close()
+}
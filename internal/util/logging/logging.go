@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+var (
+	mu    sync.Mutex
+	level = new(slog.LevelVar)
+	// pinned is set once the level was chosen by -log-level or at runtime,
+	// the config no longer changes it then
+	pinned bool
+)
+
+func init() {
+	level.Set(slog.LevelWarn)
+}
+
+// Setup sends the default slog logger, and with it the log package, to w
+func Setup(w io.Writer) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level})))
+}
+
+// ParseLevel accepts error, warn, info and debug
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "error":
+		return slog.LevelError, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q, want error, warn, info or debug", name)
+}
+
+// Level returns the current level
+func Level() slog.Level {
+	return level.Level()
+}
+
+// SetLevel changes the level until the process exits, config reloads keep it
+func SetLevel(l slog.Level) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	pinned = true
+	level.Set(l)
+}
+
+// SetConfigLevel applies the level from config.toml, unless SetLevel chose
+// one already
+func SetConfigLevel(l slog.Level) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !pinned {
+		level.Set(l)
+	}
+}
@@ -0,0 +1,95 @@
+package xdg
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DesktopEntry holds the fields of a .desktop file notifications use
+type DesktopEntry struct {
+	Name string
+	Icon string
+}
+
+var desktopCache = struct {
+	mu      sync.Mutex
+	entries map[string]*DesktopEntry
+}{entries: make(map[string]*DesktopEntry)}
+
+// LookupDesktopEntry finds the .desktop file for a desktop-entry hint such
+// as "org.gnome.Nautilus". Results, misses included, are cached since the
+// same apps notify over and over.
+func LookupDesktopEntry(id string) (DesktopEntry, bool) {
+	id = strings.TrimSuffix(id, ".desktop")
+	if id == "" || strings.Contains(id, "/") {
+		return DesktopEntry{}, false
+	}
+
+	desktopCache.mu.Lock()
+	defer desktopCache.mu.Unlock()
+
+	entry, cached := desktopCache.entries[id]
+	if !cached {
+		entry = findDesktopEntry(id)
+		desktopCache.entries[id] = entry
+	}
+
+	if entry == nil {
+		return DesktopEntry{}, false
+	}
+	return *entry, true
+}
+
+func findDesktopEntry(id string) *DesktopEntry {
+	for _, dir := range DataDirs() {
+		entry, err := readDesktopEntry(filepath.Join(dir, "applications", id+".desktop"))
+		if err == nil {
+			return entry
+		}
+	}
+	return nil
+}
+
+// readDesktopEntry reads Name and Icon from the [Desktop Entry] group
+func readDesktopEntry(path string) (*DesktopEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entry := &DesktopEntry{}
+	inEntry := false
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			inEntry = line == "[Desktop Entry]"
+			continue
+		}
+		if !inEntry {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "Name":
+			entry.Name = strings.TrimSpace(value)
+		case "Icon":
+			entry.Icon = strings.TrimSpace(value)
+		}
+	}
+
+	return entry, scanner.Err()
+}
@@ -0,0 +1,267 @@
+package xdg
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// iconExtensions are the formats eww can display, in order of preference
+var iconExtensions = []string{".svg", ".png", ".xpm"}
+
+// iconDir is one size directory listed in a theme's index.theme
+type iconDir struct {
+	path      string
+	size      int
+	minSize   int
+	maxSize   int
+	threshold int
+	kind      string
+}
+
+// distance is how far the directory's icons are from size, 0 if they fit
+func (d iconDir) distance(size int) int {
+	switch d.kind {
+	case "Fixed":
+		return abs(d.size - size)
+	case "Scalable":
+		if size < d.minSize {
+			return d.minSize - size
+		}
+		if size > d.maxSize {
+			return size - d.maxSize
+		}
+		return 0
+	default: // "Threshold"
+		if size < d.size-d.threshold {
+			return d.size - d.threshold - size
+		}
+		if size > d.size+d.threshold {
+			return size - d.size - d.threshold
+		}
+		return 0
+	}
+}
+
+type iconTheme struct {
+	inherits []string
+	dirs     []iconDir
+}
+
+var iconCache = struct {
+	mu     sync.Mutex
+	icons  map[string]string
+	themes map[string]*iconTheme
+}{
+	icons:  make(map[string]string),
+	themes: make(map[string]*iconTheme),
+}
+
+// LookupIcon resolves a themed icon name such as "firefox" to a file,
+// searching theme and the themes it inherits from before hicolor, and
+// preferring icons closest to size. Names that already are paths are
+// returned as they are.
+func LookupIcon(name, theme string, size int) (string, bool) {
+	if name == "" {
+		return "", false
+	}
+	if filepath.IsAbs(name) {
+		return name, true
+	}
+
+	key := theme + "/" + strconv.Itoa(size) + "/" + name
+
+	iconCache.mu.Lock()
+	defer iconCache.mu.Unlock()
+
+	path, cached := iconCache.icons[key]
+	if !cached {
+		path = findIcon(name, theme, size)
+		iconCache.icons[key] = path
+	}
+	return path, path != ""
+}
+
+// iconBaseDirs are the directories icon themes live in
+func iconBaseDirs() []string {
+	var dirs []string
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".icons"))
+	}
+	for _, dir := range DataDirs() {
+		dirs = append(dirs, filepath.Join(dir, "icons"))
+	}
+	return dirs
+}
+
+func findIcon(name, theme string, size int) string {
+	visited := make(map[string]bool)
+	if theme != "" {
+		if path := findThemedIcon(name, theme, size, visited); path != "" {
+			return path
+		}
+	}
+	if path := findThemedIcon(name, "hicolor", size, visited); path != "" {
+		return path
+	}
+
+	// Unthemed icons
+	for _, dir := range append(iconBaseDirs(), "/usr/share/pixmaps") {
+		for _, ext := range iconExtensions {
+			path := filepath.Join(dir, name+ext)
+			if fileExists(path) {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// findThemedIcon looks in theme, then in the themes it inherits from
+func findThemedIcon(name, themeName string, size int, visited map[string]bool) string {
+	if visited[themeName] {
+		return ""
+	}
+	visited[themeName] = true
+
+	theme := loadIconTheme(themeName)
+	if theme == nil {
+		return ""
+	}
+
+	bestPath, bestDistance := "", -1
+	for _, base := range iconBaseDirs() {
+		for _, dir := range theme.dirs {
+			distance := dir.distance(size)
+			if bestDistance >= 0 && distance >= bestDistance {
+				continue
+			}
+			for _, ext := range iconExtensions {
+				path := filepath.Join(base, themeName, dir.path, name+ext)
+				if fileExists(path) {
+					bestPath, bestDistance = path, distance
+					break
+				}
+			}
+			if bestDistance == 0 {
+				return bestPath
+			}
+		}
+	}
+	if bestPath != "" {
+		return bestPath
+	}
+
+	for _, parent := range theme.inherits {
+		if path := findThemedIcon(name, parent, size, visited); path != "" {
+			return path
+		}
+	}
+	return ""
+}
+
+// loadIconTheme parses the first index.theme found for name, nil if the
+// theme is not installed
+func loadIconTheme(name string) *iconTheme {
+	if theme, cached := iconCache.themes[name]; cached {
+		return theme
+	}
+
+	var theme *iconTheme
+	for _, base := range iconBaseDirs() {
+		parsed, err := readIndexTheme(filepath.Join(base, name, "index.theme"))
+		if err == nil {
+			theme = parsed
+			break
+		}
+	}
+
+	iconCache.themes[name] = theme
+	return theme
+}
+
+func readIndexTheme(path string) (*iconTheme, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	groups := make(map[string]map[string]string)
+	current := ""
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = line[1 : len(line)-1]
+			groups[current] = make(map[string]string)
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || current == "" {
+			continue
+		}
+		groups[current][strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	theme := &iconTheme{}
+	main := groups["Icon Theme"]
+	for _, parent := range strings.Split(main["Inherits"], ",") {
+		if parent = strings.TrimSpace(parent); parent != "" {
+			theme.inherits = append(theme.inherits, parent)
+		}
+	}
+	directories := append(strings.Split(main["Directories"], ","), strings.Split(main["ScaledDirectories"], ",")...)
+	for _, name := range directories {
+		name = strings.TrimSpace(name)
+		group, ok := groups[name]
+		if name == "" || !ok {
+			continue
+		}
+		// Only 1x icons, scaled ones are the same size drawn denser
+		if scale := atoiOr(group["Scale"], 1); scale != 1 {
+			continue
+		}
+
+		size := atoiOr(group["Size"], 0)
+		theme.dirs = append(theme.dirs, iconDir{
+			path:      name,
+			size:      size,
+			minSize:   atoiOr(group["MinSize"], size),
+			maxSize:   atoiOr(group["MaxSize"], size),
+			threshold: atoiOr(group["Threshold"], 2),
+			kind:      group["Type"],
+		})
+	}
+
+	return theme, nil
+}
+
+func atoiOr(value string, fallback int) int {
+	if n, err := strconv.Atoi(value); err == nil {
+		return n
+	}
+	return fallback
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
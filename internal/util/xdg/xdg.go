@@ -0,0 +1,42 @@
+package xdg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DataHome returns $XDG_DATA_HOME, defaulting to ~/.local/share, or an
+// empty string when there is no home directory
+func DataHome() string {
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		return dataHome
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "share")
+	}
+	return ""
+}
+
+// DataDirs returns $XDG_DATA_HOME followed by $XDG_DATA_DIRS, the
+// directories applications and icon themes are searched in, most
+// important first
+func DataDirs() []string {
+	var dirs []string
+
+	if dataHome := DataHome(); dataHome != "" {
+		dirs = append(dirs, dataHome)
+	}
+
+	dataDirs := os.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+	for _, dir := range strings.Split(dataDirs, ":") {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return dirs
+}